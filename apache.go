@@ -0,0 +1,317 @@
+package hunkee
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrApacheSyntax = errors.New("malformed apache LogFormat")
+
+// apacheDirectives maps Apache LogFormat directives to the tags.
+var apacheDirectives = map[byte]string{
+	'a': "remote_addr",
+	'A': "local_addr",
+	'B': "body_bytes_sent",
+	'b': "body_bytes_sent",
+	'D': "request_time_us",
+	'f': "filename",
+	'h': "remote_host",
+	'H': "protocol",
+	'I': "bytes_received",
+	'k': "keepalive_requests",
+	'l': "remote_logname",
+	'L': "log_id",
+	'm': "method",
+	'O': "bytes_sent",
+	'p': "server_port",
+	'P': "pid",
+	'q': "query_string",
+	'r': "request",
+	'R': "handler",
+	's': "status",
+	'S': "bytes_transferred",
+	't': "time",
+	'T': "request_time",
+	'u': "remote_user",
+	'U': "url_path",
+	'v': "server_name",
+	'V': "host",
+	'X': "connection_status",
+}
+
+// apacheHeaderPrefixes maps directives with {param} to the tag prefixes.
+// Parameter is lowercased and '-' is replaced with '_', so %{User-Agent}i
+// is mapped to "http_user_agent".
+var apacheHeaderPrefixes = map[byte]string{
+	'i': "http_",
+	'o': "sent_http_",
+	'e': "env_",
+	'n': "note_",
+	'C': "cookie_",
+	'^': "trailer_",
+}
+
+// apacheDurationUnits holds units of request time directives
+var apacheDurationUnits = map[string]time.Duration{
+	"request_time":    time.Second,
+	"request_time_ms": time.Millisecond,
+	"request_time_us": time.Microsecond,
+}
+
+// strftimeLayouts maps strftime conversions to the Go time layouts
+var strftimeLayouts = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'D': "01/02/06",
+	'e': "_2",
+	'F': "2006-01-02",
+	'h': "Jan",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'R': "15:04",
+	'S': "05",
+	'T': "15:04:05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+	'%': "%",
+}
+
+// apacheFormat is a compiled Apache LogFormat
+type apacheFormat struct {
+	names   []*namedParameter
+	layouts map[string]string        // time layouts by tag
+	units   map[string]time.Duration // duration units by tag
+}
+
+// NewParserFromApache creates parser from Apache LogFormat string, e.g.
+//
+//	%h %l %u %t \"%r\" %>s %b
+//
+// Whole directive 'LogFormat "..." nickname' is accepted too. Each directive
+// is mapped to the tag (see apacheDirectives), %{Header}i is mapped to
+// http_header, %{Header}o to sent_http_header. Time layouts of %t and
+// %{format}t and units of %D and %T are set up automatically.
+func NewParserFromApache(format string, to interface{}) (*Parser, error) {
+	format, err := parseApacheDirective(format)
+	if err != nil {
+		return nil, err
+	}
+	af, err := extractApacheNames(format)
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := buildMapper(af.names, to, defaultRegistry)
+	if err != nil {
+		return nil, err
+	}
+
+	for tag, layout := range af.layouts {
+		// layout set up in tag takes precedence
+		if f := mapper.fields[tag]; f.timeOptions != nil && !f.layoutSet {
+			f.setLayout(layout)
+		}
+	}
+	for tag, unit := range af.units {
+		if f := mapper.fields[tag]; f.ftype == typeDuration {
+			f.durationUnit = unit
+		}
+	}
+
+	p := newParser(mapper)
+	p.SetUnescape(true)
+	return p, nil
+}
+
+// parseApacheDirective strips LogFormat keyword and quotes around format
+// string and replaces escape sequences in it.
+func parseApacheDirective(directive string) (string, error) {
+	s := strings.TrimSpace(directive)
+	if len(s) > len("LogFormat") && strings.EqualFold(s[:len("LogFormat")], "LogFormat") &&
+		(s[len("LogFormat")] == ' ' || s[len("LogFormat")] == '\t') {
+		s = strings.TrimSpace(s[len("LogFormat"):])
+	}
+
+	quoted := strings.HasPrefix(s, `"`)
+	if quoted {
+		s = s[1:]
+	}
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b = append(b, '\n')
+			case 't':
+				b = append(b, '\t')
+			default:
+				b = append(b, s[i])
+			}
+		case s[i] == '"' && quoted:
+			// the rest is a nickname of format
+			return string(b), nil
+		default:
+			b = append(b, s[i])
+		}
+	}
+
+	if quoted {
+		return "", fmt.Errorf("%w: unterminated format string", ErrApacheSyntax)
+	}
+	return string(b), nil
+}
+
+// extractApacheNames compiles Apache format string into sequence of named
+// parameters. Each %-directive becomes token, anything else is literal.
+func extractApacheNames(format string) (*apacheFormat, error) {
+	var (
+		af = &apacheFormat{
+			names:   make([]*namedParameter, 0),
+			layouts: make(map[string]string),
+			units:   make(map[string]time.Duration),
+		}
+		literal []byte
+	)
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal = append(literal, format[i])
+			continue
+		}
+
+		// skip modifiers like %>s, %400,501{User-agent}i or %!200{Referer}i
+		j := i + 1
+		for j < len(format) && strings.IndexByte("<>!,0123456789", format[j]) >= 0 {
+			j++
+		}
+
+		var param string
+		if j < len(format) && format[j] == '{' {
+			end := strings.IndexByte(format[j:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated parameter at pos %d", ErrApacheSyntax, j)
+			}
+			param = format[j+1 : j+end]
+			j += end + 1
+		}
+		if j >= len(format) {
+			return nil, fmt.Errorf("%w: unterminated directive at pos %d", ErrApacheSyntax, i)
+		}
+
+		d := format[j]
+		i = j
+		if d == '%' {
+			literal = append(literal, '%')
+			continue
+		}
+
+		tag, err := af.directiveTag(d, param)
+		if err != nil {
+			return nil, err
+		}
+
+		lead := string(literal)
+		literal = literal[:0]
+		if d == 't' && param == "" {
+			// [10/Oct/2000:13:55:36 -0700], brackets are part of directive
+			lead += "["
+			literal = append(literal, ']')
+		}
+
+		af.names = append(af.names, &namedParameter{
+			name: tag, strPos: len(af.names), lead: lead,
+		})
+	}
+
+	compileParams(af.names, string(literal))
+	return af, nil
+}
+
+// directiveTag returns tag of directive d with optional {param}
+func (af *apacheFormat) directiveTag(d byte, param string) (string, error) {
+	if prefix, ok := apacheHeaderPrefixes[d]; ok && param != "" {
+		return prefix + strings.ToLower(strings.ReplaceAll(param, "-", "_")), nil
+	}
+
+	switch {
+	case d == 't':
+		return af.timeTag(param)
+	case d == 'T' && param != "":
+		tag := map[string]string{
+			"s": "request_time", "ms": "request_time_ms", "us": "request_time_us",
+		}[param]
+		if tag == "" {
+			return "", fmt.Errorf("%w: unknown unit %q of %%T", ErrApacheSyntax, param)
+		}
+		af.units[tag] = apacheDurationUnits[tag]
+		return tag, nil
+	case d == 'a' && param == "c":
+		return "client_addr", nil
+	case d == 'p' && param != "":
+		return param + "_port", nil
+	case d == 'P' && param != "":
+		return param, nil
+	}
+
+	tag, ok := apacheDirectives[d]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown directive %%%c", ErrApacheSyntax, d)
+	}
+	if unit, ok := apacheDurationUnits[tag]; ok {
+		af.units[tag] = unit
+	}
+	return tag, nil
+}
+
+// timeTag returns tag of %{format}t directive and sets up its layout
+func (af *apacheFormat) timeTag(format string) (string, error) {
+	format = strings.TrimPrefix(strings.TrimPrefix(format, "begin:"), "end:")
+	switch format {
+	case "":
+		af.layouts["time"] = TimeLocalLayout
+		return "time", nil
+	case "sec", "msec", "usec", "msec_frac", "usec_frac":
+		return "time_" + format, nil
+	}
+
+	layout, err := strftimeToLayout(format)
+	if err != nil {
+		return "", err
+	}
+	af.layouts["time"] = layout
+	return "time", nil
+}
+
+// strftimeToLayout converts strftime format into Go time layout
+func strftimeToLayout(format string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		if i == len(format)-1 {
+			return "", fmt.Errorf("%w: unterminated time conversion in %q", ErrApacheSyntax, format)
+		}
+		i++
+		layout, ok := strftimeLayouts[format[i]]
+		if !ok {
+			return "", fmt.Errorf("%w: unsupported time conversion %%%c", ErrApacheSyntax, format[i])
+		}
+		b.WriteString(layout)
+	}
+	return b.String(), nil
+}
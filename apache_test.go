@@ -0,0 +1,117 @@
+package hunkee
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewParserFromApache(t *testing.T) {
+	var s struct {
+		RemoteHost string        `hunk:"remote_host"`
+		Logname    string        `hunk:"remote_logname"`
+		RemoteUser string        `hunk:"remote_user"`
+		Time       time.Time     `hunk:"time"`
+		Request    string        `hunk:"request"`
+		Status     int           `hunk:"status"`
+		Size       string        `hunk:"body_bytes_sent"`
+		Referer    string        `hunk:"http_referer"`
+		UserAgent  string        `hunk:"http_user_agent"`
+		Took       time.Duration `hunk:"request_time_us"`
+	}
+
+	f := `LogFormat "%h %l %u %t \"%r\" %>s %b \"%{Referer}i\" \"%{User-Agent}i\" %D" combined`
+	l := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)" 1500`
+
+	p, err := NewParserFromApache(f, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.RemoteHost != "127.0.0.1" || s.Logname != "-" || s.RemoteUser != "frank" {
+		t.Errorf("unexpected remote info: %q %q %q", s.RemoteHost, s.Logname, s.RemoteUser)
+	}
+	if _, o := s.Time.Zone(); o != -7*3600 || s.Time.Year() != 2000 || s.Time.Minute() != 55 {
+		t.Errorf("time was not parsed properly: %s", s.Time)
+	}
+	if s.Request != "GET /apache_pb.gif HTTP/1.0" {
+		t.Errorf("request was not parsed properly: %q", s.Request)
+	}
+	if s.Status != 200 || s.Size != "2326" {
+		t.Errorf("status or size was not parsed properly: %d, %q", s.Status, s.Size)
+	}
+	if s.Referer != "http://www.example.com/start.html" {
+		t.Errorf("referer was not parsed properly: %q", s.Referer)
+	}
+	if s.UserAgent != "Mozilla/4.08 [en] (Win98; I ;Nav)" {
+		t.Errorf("user agent was not parsed properly: %q", s.UserAgent)
+	}
+	if s.Took != 1500*time.Microsecond {
+		t.Errorf("request time was not parsed properly: %s", s.Took)
+	}
+}
+
+func TestNewParserFromApacheTimeFormat(t *testing.T) {
+	var s struct {
+		Time time.Time     `hunk:"time"`
+		Took time.Duration `hunk:"request_time_ms"`
+		Port int           `hunk:"remote_port"`
+	}
+
+	p, err := NewParserFromApache(`[%{%Y-%m-%d %H:%M:%S}t] %{ms}T %{remote}p`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine("[2018-07-28 21:10:45] 250 51234", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Time.Year() != 2018 || s.Time.Hour() != 21 || s.Time.Second() != 45 {
+		t.Errorf("time was not parsed properly: %s", s.Time)
+	}
+	if s.Took != 250*time.Millisecond {
+		t.Errorf("request time was not parsed properly: %s", s.Took)
+	}
+	if s.Port != 51234 {
+		t.Errorf("remote port was not parsed properly: %d", s.Port)
+	}
+}
+
+func TestNewParserFromApacheErrors(t *testing.T) {
+	var s struct {
+		Host string `hunk:"remote_host"`
+	}
+
+	bad := []string{
+		`LogFormat "%h`,
+		`%h %{Referer`,
+		`%h %J`,
+		`%h %{%Q}t`,
+		`%h %{ns}T`,
+		`%h %`,
+	}
+	for _, f := range bad {
+		if _, err := NewParserFromApache(f, &s); !errors.Is(err, ErrApacheSyntax) {
+			t.Errorf("%q: expected %s, got %v", f, ErrApacheSyntax, err)
+		}
+	}
+}
+
+func TestNewParserFromApacheTagLayout(t *testing.T) {
+	var s struct {
+		Time   time.Time `hunk:"time,layout=2006-01-02T15:04:05"`
+		Status int       `hunk:"status"`
+	}
+
+	p, err := NewParserFromApache(`%t %>s`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine(`[2000-10-10T13:55:36] 200`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Time.Hour() != 13 || s.Status != 200 {
+		t.Errorf("layout of tag should take precedence: %+v", s)
+	}
+}
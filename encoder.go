@@ -0,0 +1,274 @@
+package hunkee
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// FormatLine renders src back into log line of parser format.
+// See AppendLine for details.
+func (p *Parser) FormatLine(src interface{}) (string, error) {
+	b, err := p.AppendLine(nil, src)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// AppendLine renders src, which is the structure parser was created for or
+// pointer to it, into log line of parser format and appends it to buf.
+// Literal text of format is written as is, each field is written with its
+// time layout or list separator and wrapped into delimiters or token
+// separator. Missing values (nil pointers, invalid sql.Null*, zero values
+// of omitempty fields) are written as null marker. Non-empty _raw
+// companion is preferred to the value of field.
+//
+// Closing delimiter and backslashes in values are escaped if unescaping
+// is enabled. Otherwise ErrNotEncodable is returned for values which
+// would terminate token too early. It's returned for values of tokens
+// without delimiters which contain literal text following the token
+// (or space if there is no such text) as well.
+func (p *Parser) AppendLine(buf []byte, src interface{}) ([]byte, error) {
+	v := reflect.Indirect(reflect.ValueOf(src))
+	if !v.IsValid() || p.mapper.typ == nil || v.Type() != p.mapper.typ {
+		return buf, ErrTypeMismatch
+	}
+
+	m := p.mapper
+	for _, param := range m.params {
+		buf = append(buf, param.lead...)
+
+		open, close := param.open, param.close
+		if open == 0 {
+			open, close = m.tokenSep, m.tokenSep
+		}
+		if open != 0 {
+			buf = append(buf, open)
+		}
+
+		start := len(buf)
+		field := m.fields[param.name]
+		if field.ftype == typeIgnored {
+			buf = append(buf, m.nullMarker(field)...)
+		} else if raw := m.rawToken(field, v); raw != "" || field.index == nil {
+			// raw token is kept escaped by parser
+			buf = append(buf, raw...)
+		} else {
+			var err error
+			if buf, err = m.appendValue(buf, field, fieldByIndexOrZero(v, field.index)); err != nil {
+				return buf, fmt.Errorf("field %s: %w", field.name, err)
+			}
+			// escape closing delimiter, so it could be unescaped while parsing
+			if m.unescape && open != 0 {
+				buf = append(buf[:start], escapeToken(string(buf[start:]), close)...)
+			}
+		}
+
+		if open != 0 {
+			if !closedAt(buf[start:], close) {
+				return buf, fmt.Errorf("field %s: %w: %q", field.name, ErrNotEncodable, buf[start:])
+			}
+			buf = append(buf, close)
+		} else if !bareEnds(buf[start:], param.stop) {
+			return buf, fmt.Errorf("field %s: %w: %q", field.name, ErrNotEncodable, buf[start:])
+		}
+		if param.last {
+			buf = append(buf, param.stop...)
+		}
+	}
+	return buf, nil
+}
+
+// fieldByIndexOrZero works like fieldByIndex, but returns zero value of
+// field instead of allocating nil pointer on the way.
+func fieldByIndexOrZero(v reflect.Value, index []int) reflect.Value {
+	f, ok := existingField(v, index)
+	if !ok {
+		return reflect.Zero(v.Type().FieldByIndex(index).Type)
+	}
+	return f
+}
+
+// rawToken returns value of _raw companion of field if any
+func (m *mapper) rawToken(f *field, v reflect.Value) string {
+	if !f.hasRaw {
+		return ""
+	}
+	raw := m.raw(f)
+	if raw == nil || raw.index == nil || raw.reflectType.Kind() != reflect.String {
+		return ""
+	}
+	return fieldByIndexOrZero(v, raw.index).String()
+}
+
+// nullMarker returns token which stands for missing value of field
+func (m *mapper) nullMarker(f *field) string {
+	nulls := m.nulls
+	if f.nulls != nil {
+		nulls = f.nulls
+	}
+	if len(nulls) == 0 {
+		return ""
+	}
+	return nulls[0]
+}
+
+// appendValue appends text form of v, which is either a field of structure
+// or element of slice field, to buf. It's the reverse of processValue.
+func (m *mapper) appendValue(buf []byte, field *field, v reflect.Value) ([]byte, error) {
+	if field.pointer {
+		if v.IsNil() {
+			return append(buf, m.nullMarker(field)...), nil
+		}
+		v = v.Elem()
+	}
+
+	if field.sqlNull {
+		if !v.Field(1).Bool() {
+			return append(buf, m.nullMarker(field)...), nil
+		}
+		v = v.Field(0)
+	}
+
+	if field.omitEmpty && v.IsZero() {
+		return append(buf, m.nullMarker(field)...), nil
+	}
+
+	// custom types have no reverse of their parse methods, so the
+	// standard ones are used
+	if field.custom() {
+		return appendCustom(buf, v)
+	}
+
+	switch field.ftype {
+	case typeTime:
+		t := v.Interface().(time.Time)
+		if field.timeOptions.Location != nil {
+			t = t.In(field.timeOptions.Location)
+		}
+		return t.AppendFormat(buf, field.timeOptions.Layout), nil
+	case typeDuration:
+		d := time.Duration(v.Int())
+		if field.durationUnit != 0 {
+			return strconv.AppendFloat(buf, float64(d)/float64(field.durationUnit), 'f', -1, 64), nil
+		}
+		return append(buf, d.String()...), nil
+	case typeIP:
+		ip := v.Interface().(net.IP)
+		if ip == nil {
+			return append(buf, m.nullMarker(field)...), nil
+		}
+		return append(buf, ip.String()...), nil
+	case typeURL:
+		u := v.Interface().(url.URL)
+		return append(buf, u.String()...), nil
+	case typeAddr, typeAddrPort, typePrefix:
+		if v.IsZero() {
+			return append(buf, m.nullMarker(field)...), nil
+		}
+		return append(buf, v.Interface().(fmt.Stringer).String()...), nil
+	}
+
+	switch field.reflectKind {
+	case reflect.Bool:
+		return strconv.AppendBool(buf, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(buf, v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.AppendUint(buf, v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.AppendFloat(buf, v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.AppendFloat(buf, v.Float(), 'f', -1, 64), nil
+	case reflect.String:
+		return append(buf, v.String()...), nil
+	case reflect.Slice:
+		if field.elem == nil {
+			break
+		}
+		if v.Len() == 0 {
+			return append(buf, m.nullMarker(field)...), nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf = append(buf, field.listSeps[0]...)
+			}
+			if buf, err = m.appendValue(buf, field.elem, v.Index(i)); err != nil {
+				return buf, err
+			}
+		}
+		return buf, nil
+	}
+	return buf, fmt.Errorf("%w: %s", ErrNotSupportedType, field.reflectType)
+}
+
+// appendCustom appends text form of custom type value
+// using encoding.TextMarshaler or fmt.Stringer.
+func appendCustom(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		v = v.Addr()
+	}
+	switch x := v.Interface().(type) {
+	case encoding.TextMarshaler:
+		text, err := x.MarshalText()
+		if err != nil {
+			return buf, err
+		}
+		return append(buf, text...), nil
+	case fmt.Stringer:
+		return append(buf, x.String()...), nil
+	}
+	return buf, fmt.Errorf("%w: %s implements neither encoding.TextMarshaler nor fmt.Stringer",
+		ErrNotSupportedType, v.Type())
+}
+
+// escapeToken escapes backslashes and closing delimiter in token, so it
+// could be placed between delimiters and read back with unescapeToken.
+// Delimiters other than quotes are written as \x sequence.
+func escapeToken(token string, close byte) []byte {
+	b := make([]byte, 0, len(token))
+	for i := 0; i < len(token); i++ {
+		switch c := token[i]; {
+		case c == '\\' || c == close && (c == '"' || c == '\''):
+			b = append(b, '\\', c)
+		case c == close:
+			b = append(b, fmt.Sprintf(`\x%02x`, c)...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// bareEnds reports whether token without delimiters followed by stop is
+// read back up to it, i.e. token has no stop or space if stop is empty.
+func bareEnds(token []byte, stop string) bool {
+	if stop == "" {
+		return bytes.IndexByte(token, ' ') < 0
+	}
+	return !bytes.Contains(token, []byte(stop))
+}
+
+// closedAt reports whether token followed by close is read back up to
+// it, i.e. token has no unescaped close and no trailing backslash.
+func closedAt(token []byte, close byte) bool {
+	i := 0
+	for ; i < len(token); i++ {
+		switch token[i] {
+		case '\\':
+			i++
+		case close:
+			return false
+		}
+	}
+	return i == len(token)
+}
@@ -0,0 +1,208 @@
+package hunkee
+
+import (
+	"database/sql"
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFormatLine(t *testing.T) {
+	type entry struct {
+		Addr     netip.Addr       `hunk:"remote_addr"`
+		Time     time.Time        `hunk:"time_local,layout=02/Jan/2006:15:04:05 -0700"`
+		Request  string           `hunk:"request"`
+		Status   int              `hunk:"status"`
+		Bytes    sql.NullInt64    `hunk:"bytes"`
+		Referer  *string          `hunk:"referer"`
+		RT       time.Duration    `hunk:"request_time"`
+		Upstream []netip.AddrPort `hunk:"upstream_addr"`
+		Size     uint64           `hunk:"size"`
+		SizeRaw  string           `hunk:"size_raw"`
+	}
+
+	f := `:remote_addr - [:time_local] ":request" :status :bytes ":referer" rt=:request_time ":upstream_addr" :size;`
+	p, err := NewParser(f, &entry{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetUnescape(true)
+
+	src := entry{
+		Addr:     netip.MustParseAddr("10.0.0.1"),
+		Time:     time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+		Request:  `GET /?q="hunkee" HTTP/1.1`,
+		Status:   200,
+		RT:       1500 * time.Millisecond,
+		Upstream: []netip.AddrPort{netip.MustParseAddrPort("10.0.0.2:80"), netip.MustParseAddrPort("10.0.0.3:80")},
+		Size:     1024,
+		SizeRaw:  "1kb",
+	}
+
+	l, err := p.FormatLine(&src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `10.0.0.1 - [10/Oct/2000:13:55:36 -0700] "GET /?q=\"hunkee\" HTTP/1.1" 200 - "-" rt=1.5s "10.0.0.2:80,10.0.0.3:80" 1kb;`
+	if l != expected {
+		t.Fatalf("unexpected line:\n%s\nexpected:\n%s", l, expected)
+	}
+
+	// round trip
+	src.SizeRaw = ""
+	l, err = p.FormatLine(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst entry
+	if err = p.ParseLine(l, &dst); err != nil {
+		t.Fatalf("%s: %s", l, err)
+	}
+	dst.SizeRaw = ""
+	if !dst.Time.Equal(src.Time) {
+		t.Errorf("time was not formatted properly: %s", l)
+	}
+	dst.Time = src.Time
+	if !reflect.DeepEqual(src, dst) {
+		t.Errorf("round trip failed:\n%+v\n%+v", src, dst)
+	}
+
+	b, err := p.AppendLine([]byte("> "), &src)
+	if err != nil || string(b) != "> "+l {
+		t.Errorf("unexpected line %q, %v", b, err)
+	}
+
+	if _, err = p.FormatLine(struct{}{}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("expected %s, got %v", ErrTypeMismatch, err)
+	}
+}
+
+func TestFormatLineTokenSeparator(t *testing.T) {
+	var s struct {
+		ID   int    `hunk:"id"`
+		Name string `hunk:"name"`
+	}
+
+	p, err := NewParser(":id :name", &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetTokenSeparator('"')
+
+	s.ID, s.Name = 17, "Gordon Freeman"
+	if l, err := p.FormatLine(&s); err != nil || l != `"17" "Gordon Freeman"` {
+		t.Errorf("unexpected line %q, %v", l, err)
+	}
+}
+
+func TestFormatLineRawOnly(t *testing.T) {
+	var s struct {
+		ID       int    `hunk:"id"`
+		TokenRaw string `hunk:"token_raw"`
+	}
+
+	p, err := NewParser(":id :token", &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	s.ID, s.TokenRaw = 17, "abc"
+	if l, err := p.FormatLine(&s); err != nil || l != "17 abc" {
+		t.Errorf("unexpected line %q, %v", l, err)
+	}
+}
+
+func TestFormatLineEscape(t *testing.T) {
+	type entry struct {
+		Time    string `hunk:"time"`
+		Request string `hunk:"request"`
+	}
+
+	p, err := NewParser(`[:time] ":request"`, &entry{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// value is read back as is, so it could not have closing delimiter
+	for _, src := range []entry{
+		{Time: "10/Oct/2000", Request: `GET /?q="hunkee"`},
+		{Time: "[10/Oct/2000]", Request: "GET /"},
+		{Time: "10/Oct/2000", Request: `GET /\`},
+	} {
+		if l, err := p.FormatLine(&src); !errors.Is(err, ErrNotEncodable) {
+			t.Errorf("%+v: expected %s, got %q, %v", src, ErrNotEncodable, l, err)
+		}
+	}
+	src := entry{Time: "10/Oct/2000", Request: `GET /?q=\"hunkee\"`}
+	if l, err := p.FormatLine(&src); err != nil || l != `[10/Oct/2000] "GET /?q=\"hunkee\""` {
+		t.Errorf("unexpected line %q, %v", l, err)
+	}
+
+	// delimiters and backslashes are escaped for unescaping parser
+	p.SetUnescape(true)
+	src = entry{Time: "[10/Oct/2000]", Request: `GET /?q="hunkee"\`}
+	l, err := p.FormatLine(&src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[[10/Oct/2000\x5d] "GET /?q=\"hunkee\"\\"`; l != expected {
+		t.Fatalf("unexpected line:\n%s\nexpected:\n%s", l, expected)
+	}
+	var dst entry
+	if err = p.ParseLine(l, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst != src {
+		t.Errorf("round trip failed:\n%+v\n%+v", src, dst)
+	}
+}
+
+func TestFormatLineBareTokens(t *testing.T) {
+	type entry struct {
+		A string `hunk:"a"`
+		B string `hunk:"b"`
+	}
+
+	p, err := NewParser(":a :b", &entry{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	q, err := NewParser("a=:a;b=:b", &entry{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// bare token is read up to the following literal or space
+	for _, c := range []struct {
+		p   *Parser
+		src entry
+	}{
+		{p, entry{A: "x y", B: "z"}},
+		{p, entry{A: "x", B: "y z"}},
+		{q, entry{A: "x;b=y", B: "z"}},
+	} {
+		if l, err := c.p.FormatLine(&c.src); !errors.Is(err, ErrNotEncodable) {
+			t.Errorf("%+v: expected %s, got %q, %v", c.src, ErrNotEncodable, l, err)
+		}
+	}
+
+	// round trip
+	for _, c := range []struct {
+		p   *Parser
+		src entry
+	}{
+		{p, entry{A: "x;y", B: "z"}},
+		{q, entry{A: "x y", B: "z"}},
+	} {
+		l, err := c.p.FormatLine(&c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dst entry
+		if err = c.p.ParseLine(l, &dst); err != nil || dst != c.src {
+			t.Errorf("round trip of %q failed: %+v, %v", l, dst, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package hunkee
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrorMode defines how parser deals with fields which could not be parsed
+type ErrorMode int
+
+const (
+	// Strict mode stops parsing on the first failed field and returns its error
+	Strict ErrorMode = iota
+	// Lenient mode skips failed fields leaving them zero (nil pointers,
+	// invalid sql.Null*). Error is returned only if line does not match format.
+	Lenient
+	// CollectAll mode skips failed fields like Lenient does, but returns
+	// errors of all of them joined with errors.Join
+	CollectAll
+)
+
+// ParseError describes failure of parsing line: which token has failed,
+// where it is placed and why. Cause is accessible with errors.Is and
+// errors.As, e.g. errors.Is(err, ErrLessTokens).
+type ParseError struct {
+	Tag    string // tag of the field token belongs to
+	Index  int    // index of token in format string
+	Offset int    // byte offset of token in line
+	Token  string // token which failed to parse, empty if it was not cut
+	Line   string // parsed line, could be truncated (see SetErrorLineLimit)
+	Err    error  // cause
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("token %d (%s) at pos %d: %s", e.Index, e.Tag, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("token %d (%s) at pos %d: %q: %s", e.Index, e.Tag, e.Offset, e.Token, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseError builds ParseError. Line and token are copied, since they
+// could share memory with buffer passed to ParseBytes.
+func (m *mapper) parseError(tag string, index, offset int, token, line string, err error) *ParseError {
+	if m.errLineLimit > 0 && len(line) > m.errLineLimit {
+		end := m.errLineLimit
+		// don't cut line in the middle of rune
+		for end > 0 && !utf8.RuneStart(line[end]) {
+			end--
+		}
+		line = line[:end] + "..."
+	}
+	return &ParseError{
+		Tag:    tag,
+		Index:  index,
+		Offset: offset,
+		Token:  strings.Clone(token),
+		Line:   strings.Clone(line),
+		Err:    err,
+	}
+}
@@ -0,0 +1,119 @@
+package hunkee
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	var s struct {
+		ID      int        `hunk:"id"`
+		Name    string     `hunk:"name"`
+		Complex complex128 `hunk:"complex"`
+	}
+
+	p, err := NewParser(`id=:id [:name]`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = p.ParseLine("id=17 [Gordon", &s)
+	var perr *ParseError
+	if !errors.As(err, &perr) || !errors.Is(err, ErrUnterminatedToken) {
+		t.Fatalf("expected ParseError caused by %s, got %v", ErrUnterminatedToken, err)
+	}
+	if perr.Tag != "name" || perr.Index != 1 || perr.Offset != 6 || perr.Line != "id=17 [Gordon" {
+		t.Errorf("unexpected error details: %+v", perr)
+	}
+
+	line := []byte("id=1x7 [Gordon]")
+	err = p.ParseBytes(line, &s)
+	if !errors.As(err, &perr) || !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("expected ParseError caused by %s, got %v", strconv.ErrSyntax, err)
+	}
+	copy(line, "xxx")
+	if perr.Tag != "id" || perr.Index != 0 || perr.Offset != 3 || perr.Token != "1x7" || perr.Line != "id=1x7 [Gordon]" {
+		t.Errorf("unexpected error details: %+v", perr)
+	}
+
+	if err = p.ParseLine("id=17", &s); !errors.Is(err, ErrLessTokens) {
+		t.Errorf("expected %s, got %v", ErrLessTokens, err)
+	}
+	if err = p.ParseLine("17 [Gordon]", &s); !errors.Is(err, ErrUnexpectedLiteral) {
+		t.Errorf("expected %s, got %v", ErrUnexpectedLiteral, err)
+	}
+
+	// line is not cut in the middle of rune
+	p.SetErrorLineLimit(3)
+	if err = p.ParseLine("ид=17 [Gordon]", &s); !errors.As(err, &perr) || perr.Line != "и..." {
+		t.Errorf("expected truncated line, got %v", perr)
+	}
+
+	p, err = NewParser(`:complex`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine("1+2i", &s); !errors.Is(err, ErrNotSupportedType) {
+		t.Errorf("expected %s, got %v", ErrNotSupportedType, err)
+	}
+}
+
+func TestSetErrorMode(t *testing.T) {
+	var s struct {
+		Status int           `hunk:"status"`
+		Time   float64       `hunk:"request_time"`
+		Size   sql.NullInt64 `hunk:"size"`
+		Port   *int          `hunk:"port"`
+		Name   string        `hunk:"name"`
+	}
+
+	p, err := NewParser(":status :request_time :size :port :name", &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	line := "200 fast big https Gordon"
+	if err = p.ParseLine(line, &s); !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("strict mode: expected %s, got %v", strconv.ErrSyntax, err)
+	}
+	if s.Time != 0 || s.Name != "" {
+		t.Errorf("strict mode: parsing should be stopped on the first error: %+v", s)
+	}
+
+	port := 80
+	s.Time, s.Size, s.Port = 0.5, sql.NullInt64{Int64: 1, Valid: true}, &port
+	p.SetErrorMode(Lenient)
+	if err = p.ParseLine(line, &s); err != nil {
+		t.Fatalf("lenient mode: unexpected error: %s", err)
+	}
+	if s.Status != 200 || s.Time != 0 || s.Size.Valid || s.Port != nil || s.Name != "Gordon" {
+		t.Errorf("lenient mode: failed fields should be zero: %+v", s)
+	}
+	if err = p.ParseLine("200 fast", &s); !errors.Is(err, ErrLessTokens) {
+		t.Errorf("lenient mode: expected %s, got %v", ErrLessTokens, err)
+	}
+
+	p.SetErrorMode(CollectAll)
+	err = p.ParseLine(line, &s)
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("collect all mode: expected joined errors, got %v", err)
+	}
+	var tags []string
+	for _, err := range joined.Unwrap() {
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Fatalf("collect all mode: expected ParseError, got %v", err)
+		}
+		tags = append(tags, perr.Tag)
+	}
+	if fmt.Sprint(tags) != "[request_time size port]" {
+		t.Errorf("collect all mode: unexpected failed fields %v", tags)
+	}
+	if s.Status != 200 || s.Name != "Gordon" {
+		t.Errorf("collect all mode: valid fields should be parsed: %+v", s)
+	}
+}
@@ -2,6 +2,7 @@ package hunkee
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"reflect"
 	"strings"
@@ -15,15 +16,43 @@ const (
 var debug bool
 
 // parseLine processing one log line into structure
-func (p *Parser) parseLine(line string, dest interface{}) (err error) {
+func (p *Parser) parseLine(line string, dest interface{}) error {
+	return p.parse(line, dest, false)
+}
+
+// parse processing one log line into structure. If line is borrowed
+// (shares memory with caller's buffer), tokens are copied before
+// they could be retained by destination.
+func (p *Parser) parse(line string, dest interface{}, borrowed bool) error {
+	destination := reflect.Indirect(reflect.ValueOf(dest))
+	if p.mapper.resets != nil && !p.mapper.commented(line) {
+		p.mapper.reset(destination)
+	}
+	return p.tokenize(line, func(field *field, token string) error {
+		if borrowed && field.retainsToken() {
+			token = strings.Clone(token)
+		}
+		err := p.mapper.processField(field, destination, token)
+		if err != nil && p.mapper.errMode != Strict {
+			// failed field is left zero, like missing one
+			fieldByIndex(destination, field.index).Set(reflect.Zero(field.reflectType))
+		}
+		return err
+	})
+}
+
+// tokenize cuts line into tokens and passes each of them with
+// corresponded field to process. Commented lines are skipped. Errors
+// of process are handled according to error mode of parser.
+func (p *Parser) tokenize(line string, process func(field *field, token string) error) (err error) {
 	if line == "" || line == "\n" {
 		return ErrEmptyLine
 	}
 
 	var (
-		offset  int
-		lineLen = len(line)
-		w       = p.mapper.aquireWorker()
+		offset int
+		errs   []error
+		w      = p.mapper.aquireWorker()
 	)
 
 	defer w.free()
@@ -33,58 +62,177 @@ func (p *Parser) parseLine(line string, dest interface{}) (err error) {
 	}
 
 	// Check if line has commentary prefix. If so, skip
-	if p.mapper.prefixActive && strings.HasPrefix(line, p.mapper.comPrefix) {
+	if p.mapper.commented(line) {
 		if debug {
 			log.Printf("Entry: %q skipped due to matched prefix %q", line, p.mapper.comPrefix)
 		}
 		return
 	}
 
-	destination := reflect.Indirect(reflect.ValueOf(dest))
-	for field := w.first(); field != nil; field = w.next() {
-		var token string
+	line = strings.TrimRight(line, "\r\n")
+	for i, field := 0, w.first(); field != nil; i, field = i+1, w.next() {
+		var (
+			token string
+			start int
+		)
+
+		token, start, offset, err = p.mapper.cutToken(line, offset, w.param())
+		if err != nil {
+			// rest of line could not be matched with format anyway
+			errs = append(errs, p.mapper.parseError(w.param().name, i, start, "", line, err))
+			break
+		}
+		if field.ftype == typeIgnored {
+			continue
+		}
 
-		if offset < 0 {
-			return errors.New("provided line has less tokens than expected")
+		if debug {
+			log.Printf("Field: %q Token: %q Pos:[%d:%d] HasRaw: %t TimeOption: %#+v\n",
+				field.name, token, start, offset, field.hasRaw, field.timeOptions)
 		}
 
-		start := offset
-		// if token separator is 0, no need to search first occurrence
-		if p.mapper.tokenSep != 0 {
-			start = findNextSep(line, offset, p.mapper.tokenSep)
-			if start < 0 {
-				return errors.New("provided line has less tokens than expected")
+		if err = process(field, token); err != nil {
+			perr := p.mapper.parseError(field.name, i, start, token, line, err)
+			switch p.mapper.errMode {
+			case Strict:
+				return perr
+			case CollectAll:
+				errs = append(errs, perr)
 			}
 		}
-		end := findNextSep(line, start, p.mapper.tokenSep)
+	}
+
+	switch {
+	case len(errs) == 0:
+		return nil
+	case p.mapper.errMode == CollectAll:
+		return errors.Join(errs...)
+	}
+	return errs[0]
+}
+
+// commented reports whether line has commentary prefix
+func (m *mapper) commented(line string) bool {
+	return m.prefixActive && strings.HasPrefix(line, m.comPrefix)
+}
 
-		// findNextSpace returns -1 if no other space found
-		// so if no space found - read line from current position
-		// to the end of line, else read all between offset and end
-		if end < offset || end >= lineLen-1 {
-			token = line[offset:]
-		} else {
-			token = line[offset:end]
+// reset zeroes fields of destination which could be populated by parser.
+// Nil pointers to nested structs are not allocated.
+func (m *mapper) reset(destination reflect.Value) {
+	for _, f := range m.resets {
+		if v, ok := existingField(destination, f.index); ok {
+			v.Set(reflect.Zero(f.reflectType))
 		}
-		if field.ftype == typeIgnored {
-			offset = end
-			continue
+	}
+}
+
+// cutToken matches literal text before token described by param and
+// returns token value, its offset and offset right after it. On failure
+// offset of mismatch is returned.
+func (m *mapper) cutToken(line string, offset int, param *namedParameter) (string, int, int, error) {
+	if param.lead != "" {
+		if !strings.HasPrefix(line[offset:], param.lead) {
+			if offset >= len(line) {
+				return "", offset, offset, ErrLessTokens
+			}
+			return "", offset, offset, fmt.Errorf("%w: %q expected", ErrUnexpectedLiteral, param.lead)
 		}
+		offset += len(param.lead)
+	}
 
-		token = strings.Trim(strings.TrimSpace(token), string(p.mapper.tokenSep))
+	// token without own delimiters falls back to token separator
+	open, close := param.open, param.close
+	if open == 0 {
+		open, close = m.tokenSep, m.tokenSep
+	}
 
-		if debug {
-			log.Printf("Field: %q Token: %q Pos:[%d:%d] HasRaw: %t TimeOption: %#+v\n",
-				field.name, token, offset, end, field.hasRaw, field.timeOptions)
+	// token wrapped into delimiters: read till the closing one.
+	// Delimiters are optional, so bare '-' could stand for "-".
+	if open != 0 && offset < len(line) && line[offset] == open {
+		end := indexUnescaped(line[offset+1:], close)
+		if end < 0 {
+			return "", offset, offset, fmt.Errorf("%w: %q expected", ErrUnterminatedToken, close)
+		}
+		return line[offset+1 : offset+1+end], offset + 1, offset + end + 2, nil
+	}
+
+	if offset >= len(line) && !param.last {
+		return "", offset, offset, ErrLessTokens
+	}
+
+	var (
+		rest = line[offset:]
+		end  int
+	)
+	if param.stop != "" {
+		end = strings.Index(rest, param.stop)
+		if next := param.next; next != nil && next.open != 0 && next.lead != "" {
+			end = m.indexBareStop(rest, end, next)
+		}
+	} else {
+		// no literal after token, so space is the only possible bound
+		end = findNextSep(rest, -1, ' ')
+	}
+
+	if end < 0 {
+		if !param.last {
+			return "", offset, offset, ErrLessTokens
 		}
+		end = len(rest)
+	}
+	return rest[:end], offset, offset + end, nil
+}
+
+// indexBareStop returns end of bare token followed by token next, which
+// delimiters are missed in line. end is an index of stop with delimiter.
+// Null marker of next token without delimiters found before end takes
+// precedence, e.g. for ':bytes ":referer"' and '0 - "curl"' bytes are "0".
+// If there is no stop at all, lead of next token alone is used.
+func (m *mapper) indexBareStop(rest string, end int, next *namedParameter) int {
+	for i := 0; end < 0 || i < end; {
+		j := strings.Index(rest[i:], next.lead)
+		if j < 0 || end >= 0 && i+j >= end {
+			break
+		}
+		j += i
+		if m.bareNullAt(rest[j+len(next.lead):], next) {
+			return j
+		}
+		i = j + 1
+	}
+	if end < 0 {
+		return strings.Index(rest, next.lead)
+	}
+	return end
+}
 
-		if err = p.mapper.processField(field, destination, token); err != nil {
-			return err
+// bareNullAt reports whether s starts with null marker of token p
+// without delimiters, which is followed by the end of token p.
+func (m *mapper) bareNullAt(s string, p *namedParameter) bool {
+	nulls := m.nulls
+	if f := m.fields[p.name]; f != nil && f.nulls != nil {
+		nulls = f.nulls
+	}
+	for _, null := range nulls {
+		if null != "" && strings.HasPrefix(s, null) && p.ends(s[len(null):]) {
+			return true
 		}
+	}
+	return false
+}
 
-		offset = end
+// indexUnescaped returns index of the first c in s which is not
+// escaped by backslash, or -1 if there is no such byte.
+func indexUnescaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
 	}
-	return
+	return -1
 }
 
 // if provided sep is empty, space lookup will be used instead
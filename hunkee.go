@@ -2,7 +2,10 @@ package hunkee
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
 	"time"
+	"unsafe"
 )
 
 var (
@@ -14,27 +17,87 @@ var (
 	ErrNotFloat     = errors.New("corresponded kind is not Float32 or Float64")
 	ErrEmptyLine    = errors.New("empty line passed")
 
+	// Deprecated: tag options are supported now, see ErrUnknownOption.
 	ErrComaNotSupported = errors.New("coma-separated tag options is not supported")
 	ErrUnexpectedColon  = errors.New("unexpected ':' while parsing format string")
 	ErrNotSupportedType = errors.New("corresponded kind is not supported")
 	ErrNilTimeOptions   = errors.New("nil time options, time cannot be parsed")
+	ErrUnknownOption    = errors.New("unknown or inapplicable tag option")
+	ErrTypeMismatch     = errors.New("destination is not a pointer to the struct parser was created for")
+	ErrNotEncodable     = errors.New("value could not be read back from token")
+
+	ErrLessTokens        = errors.New("provided line has less tokens than expected")
+	ErrUnexpectedLiteral = errors.New("line does not match literal text of format")
+	ErrUnterminatedToken = errors.New("unterminated token")
 )
 
+// Unmarshaler is implemented by types which could parse token themselves.
+// Fields of such types are populated by calling UnmarshalHunk with token.
+// Fields implementing encoding.TextUnmarshaler are supported too.
+type Unmarshaler interface {
+	UnmarshalHunk(token string) error
+}
+
 type Parser struct {
 	mapper *mapper
 	debug  bool
+	types  *registry // types registered by options while fields are mapped
 }
 
-func NewParser(format string, to interface{}) (*Parser, error) {
-	mapper, err := initMapper(format, to)
+// NewParser creates parser of format string for passed structure. Options
+// are applied in order, e.g. WithValidation makes it fail on any issue
+// reported by Validate.
+func NewParser(format string, to interface{}, opts ...ParserOption) (*Parser, error) {
+	return newParserWith(opts, func(types *registry) (*mapper, error) {
+		return initMapper(format, to, types)
+	})
+}
+
+// NewParserFromStruct creates parser with format derived from the structure:
+// tokens follow in order of field declaration (fields of embedded and nested
+// structs included, _raw fields skipped) and are separated by sep.
+func NewParserFromStruct(to interface{}, sep byte) (*Parser, error) {
+	tokens, err := structNames(to, sep)
 	if err != nil {
 		return nil, err
 	}
+	mapper, err := buildMapper(tokens, to, defaultRegistry)
+	if err != nil {
+		return nil, err
+	}
+	return newParser(mapper), nil
+}
+
+// newParserWith creates parser of mapper built by build and applies opts
+// to it. Options are applied to parser without mapper first, so types
+// registered by them are known while fields are mapped.
+func newParserWith(opts []ParserOption, build func(types *registry) (*mapper, error)) (*Parser, error) {
+	setup := &Parser{types: newRegistry(defaultRegistry)}
+	for _, opt := range opts {
+		if err := opt(setup); err != nil {
+			return nil, err
+		}
+	}
+	mapper, err := build(setup.types)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newParser(mapper)
+	for _, opt := range opts {
+		if err = opt(p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func newParser(mapper *mapper) *Parser {
 	p := &Parser{
 		mapper: mapper,
 	}
 	p.SetCommentPrefix("#")
-	return p, nil
+	return p
 }
 
 type TimeOption struct {
@@ -44,10 +107,32 @@ type TimeOption struct {
 
 // ParseLine gets line of input and structure to parse in
 // Returns ErrEmptyLine if passed empty string or string with only \n
+// and ErrTypeMismatch if to is not a pointer to the structure of the
+// same type as passed to NewParser.
 func (p *Parser) ParseLine(line string, to interface{}) error {
+	if !p.acceptable(to) {
+		return ErrTypeMismatch
+	}
 	return p.parseLine(line, to)
 }
 
+// acceptable reports whether to is a pointer to the structure parser was created for
+func (p *Parser) acceptable(to interface{}) bool {
+	t := reflect.TypeOf(to)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem() == p.mapper.typ
+}
+
+// ParseBytes works like ParseLine, but takes line as byte slice without
+// converting it to string. Tokens are copied only when they are stored in
+// the structure (string fields, _raw fields and so on), so line could be
+// reused right after the call.
+func (p *Parser) ParseBytes(line []byte, to interface{}) error {
+	if !p.acceptable(to) {
+		return ErrTypeMismatch
+	}
+	return p.parse(unsafe.String(unsafe.SliceData(line), len(line)), to, true)
+}
+
 // SetDebug makes hunkee more verbose
 func (p *Parser) SetDebug(val bool) {
 	p.debug = val
@@ -58,7 +143,7 @@ func (p *Parser) SetDebug(val bool) {
 // fields in log entry. By default it's corresponded to
 // RFC3339 - "2006-01-02T15:04:05Z07:00"
 func (p *Parser) SetTimeLayout(tag, timeLayout string) {
-	p.mapper.fields[tag].timeOptions.Layout = timeLayout
+	p.mapper.fields[tag].setLayout(timeLayout)
 }
 
 // SetMultiplyTimeLayout receives map of TAG -> LAYOUT and sets up
@@ -84,6 +169,10 @@ func (p *Parser) SetTimeOption(tag string, to *TimeOption) {
 		return
 	}
 	p.mapper.fields[tag].timeOptions = to
+	p.mapper.fields[tag].layoutSet = true
+	if elem := p.mapper.fields[tag].elem; elem != nil {
+		elem.timeOptions = to
+	}
 }
 
 // TimeOption returns corresponded TimeOptions for tag
@@ -115,10 +204,91 @@ func (p *Parser) SetWorkersAmount(amount int) {
 // '"user" "123" "hunkee is slow"' with the next format line:
 // ':name :id :description'
 // The token separator here is '"'.
+//
+// Token separator is used only for tokens which have no own delimiters
+// in format string, e.g. ':id' uses it, while '":name"' and '[:date]' don't.
 func (p *Parser) SetTokenSeparator(sep byte) {
 	p.mapper.tokenSep = sep
 }
 
+// SetUnescape makes parser replace escape sequences like \" or \x22 in tokens
+// with symbols they stand for before writing value into the field.
+// Corresponded _raw fields keep escaped form of token.
+func (p *Parser) SetUnescape(val bool) {
+	p.mapper.unescape = val
+}
+
+// SetNullValues sets tokens which stand for missing value, "-" by default.
+// When such token is met, pointer field is set to nil, sql.Null* field gets
+// Valid=false, string field gets token as is and any other field gets zero value.
+func (p *Parser) SetNullValues(values ...string) {
+	p.mapper.nulls = append([]string{}, values...)
+}
+
+// SetFieldNullValues overrides null values for the field with provided tag.
+func (p *Parser) SetFieldNullValues(tag string, values ...string) {
+	p.mapper.fields[tag].nulls = append([]string{}, values...)
+}
+
+// SetResetFields makes parser zero fields of destination mapped to format
+// tokens, including _raw ones, before parsing line into it, so values of
+// previous line are not kept when destination is reused. Fields absent in
+// format are kept as is. Disabled by default.
+func (p *Parser) SetResetFields(val bool) {
+	m := p.mapper
+	m.resets = nil
+	if !val {
+		return
+	}
+	seen := make(map[*field]bool, len(m.tokensSeq))
+	add := func(f *field) {
+		// skip ignored tokens and raw placeholders
+		if f != nil && f.index != nil && !seen[f] {
+			seen[f] = true
+			m.resets = append(m.resets, f)
+		}
+	}
+	for _, tag := range m.tokensSeq {
+		f := m.fields[tag]
+		add(f)
+		if f.hasRaw {
+			add(m.raw(f))
+		}
+	}
+}
+
+// SetErrorMode sets up how fields which could not be parsed are handled,
+// Strict by default.
+func (p *Parser) SetErrorMode(mode ErrorMode) {
+	p.mapper.errMode = mode
+}
+
+// SetErrorLineLimit limits length of line kept by ParseError, longer
+// lines are truncated. Whole line is kept by default.
+func (p *Parser) SetErrorLineLimit(limit int) {
+	p.mapper.errLineLimit = limit
+}
+
+// SetListSeparator sets separators of elements for slice field with provided
+// tag, "," by default. Elements are trimmed of spaces, so nginx lists like
+// "10.0.0.1:80, 10.0.0.2:80 : 10.0.0.3:80" could be parsed with ",", " : ".
+// It panics if tag is not of slice field or separators are missing or empty.
+func (p *Parser) SetListSeparator(tag string, seps ...string) {
+	f := p.mapper.fields[tag]
+	if f == nil || f.elem == nil {
+		panic(fmt.Sprintf("tag %q is not of slice field", tag))
+	}
+	if len(seps) == 0 {
+		panic("no list separators passed")
+	}
+	for _, sep := range seps {
+		if sep == "" {
+			panic("passed empty list separator")
+		}
+	}
+	f.listSeps = append([]string{}, seps...)
+}
+
 func DefaultTimeOptions() *TimeOption {
 	return &TimeOption{
 		Layout: time.RFC3339, // default time layout "2006-01-02T15:04:05Z07:00"
@@ -60,6 +60,20 @@ func BenchmarkParseWithoutTime(b *testing.B) {
 	}
 }
 
+func BenchmarkParseBytesWithoutTime(b *testing.B) {
+	parser, err := NewParser(formatWithoutTime, bch)
+	if err != nil {
+		panic(err)
+	}
+
+	line := []byte(entryWithoutTime)
+	for i := 0; i < b.N; i++ {
+		if err := parser.ParseBytes(line, bch); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
 func BenchmarkParseRE(b *testing.B) {
 	bch := new(Beach)
 	for i := 0; i < b.N; i++ {
@@ -1,8 +1,13 @@
 package hunkee
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"reflect"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -44,6 +49,24 @@ func TestSetTimeLayout(t *testing.T) {
 	}
 }
 
+func TestParseLineRawOnly(t *testing.T) {
+	var s struct {
+		ID       int    `hunk:"id"`
+		TokenRaw string `hunk:"token_raw"`
+	}
+
+	p, err := NewParser(":id :token", &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ParseLine("17 abc", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 17 || s.TokenRaw != "abc" {
+		t.Errorf("unexpected result: %+v", s)
+	}
+}
+
 func TestSetMultiplyTimeLayouts(t *testing.T) {
 	var s struct {
 		A  time.Time `hunk:"a"`
@@ -278,3 +301,708 @@ func TestParseLineReal(t *testing.T) {
 	}
 
 }
+
+func TestParseLineEmptyTokens(t *testing.T) {
+	var s struct {
+		Count int            `hunk:"count"`
+		Took  time.Duration  `hunk:"took"`
+		Addr  netip.Addr     `hunk:"addr"`
+		Peer  netip.AddrPort `hunk:"peer"`
+		Net   netip.Prefix   `hunk:"net"`
+	}
+
+	p, err := NewParser(`":count" ":took" ":addr" ":peer" ":net"`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine(`"1" "1s" "10.0.0.1" "10.0.0.1:80" "10.0.0.0/8"`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.ParseLine(`"" "" "" "" ""`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count != 0 || s.Took != 0 || s.Addr.IsValid() || s.Peer.IsValid() || s.Net.IsValid() {
+		t.Errorf("empty tokens should give zero values, got %+v", s)
+	}
+}
+
+func TestParseLineWithLiterals(t *testing.T) {
+	var s struct {
+		RemoteAddr net.IP    `hunk:"remote_addr"`
+		RemoteUser string    `hunk:"remote_user"`
+		TimeLocal  time.Time `hunk:"time_local"`
+		Request    string    `hunk:"request"`
+		Status     int       `hunk:"status"`
+		Size       uint64    `hunk:"body_bytes_sent"`
+		Key        string    `hunk:"key"`
+	}
+
+	f := `:remote_addr - :remote_user [:time_local] ":request" :status :body_bytes_sent key=:key`
+	l := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 key=abc`
+
+	p, err := NewParser(f, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetTimeLayout("time_local", "02/Jan/2006:15:04:05 -0700")
+
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.RemoteAddr.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("remote_addr was not parsed properly: %s", s.RemoteAddr)
+	}
+	if s.RemoteUser != "frank" {
+		t.Errorf("remote_user was not parsed properly: %q != %q", s.RemoteUser, "frank")
+	}
+	if s.TimeLocal.Day() != 10 || s.TimeLocal.Hour() != 13 {
+		t.Errorf("time_local was not parsed properly: %s", s.TimeLocal)
+	}
+	if s.Request != "GET /apache_pb.gif HTTP/1.0" {
+		t.Errorf("request was not parsed properly: %q != %q", s.Request, "GET /apache_pb.gif HTTP/1.0")
+	}
+	if s.Status != 200 {
+		t.Errorf("status was not parsed properly: %d != %d", s.Status, 200)
+	}
+	if s.Size != 2326 {
+		t.Errorf("body_bytes_sent was not parsed properly: %d != %d", s.Size, 2326)
+	}
+	if s.Key != "abc" {
+		t.Errorf("key was not parsed properly: %q != %q", s.Key, "abc")
+	}
+
+	if err = p.ParseLine(`127.0.0.1 - frank 10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" 200 0 key=a`, &s); err == nil {
+		t.Error("expected error about missing literal, got nil")
+	}
+}
+
+func TestParseLinePerTokenDelimiters(t *testing.T) {
+	var s struct {
+		ID      int       `hunk:"id"`
+		Date    time.Time `hunk:"date"`
+		Request string    `hunk:"request"`
+		Agent   string    `hunk:"agent"`
+		Status  int       `hunk:"status"`
+	}
+
+	p, err := NewParser(`:id [:date] ":request" ":agent" :status`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetTimeLayout("date", "02/Jan/2006:15:04:05 -0700")
+
+	l := `17 [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" "Mozilla/5.0 (X11; Linux)" 404`
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 17 || s.Status != 404 {
+		t.Errorf("bare tokens were not parsed properly: %d, %d", s.ID, s.Status)
+	}
+	if s.Date.Minute() != 55 {
+		t.Errorf("bracketed token was not parsed properly: %s", s.Date)
+	}
+	if s.Request != "GET / HTTP/1.1" {
+		t.Errorf("quoted token was not parsed properly: %q", s.Request)
+	}
+	if s.Agent != "Mozilla/5.0 (X11; Linux)" {
+		t.Errorf("quoted token was not parsed properly: %q", s.Agent)
+	}
+
+	// delimiters are optional, global separator is used only by bare tokens
+	p.SetTokenSeparator('\'')
+	l = `'18' [10/Oct/2000:13:55:36 -0700] - "curl" '200'`
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 18 || s.Status != 200 {
+		t.Errorf("bare tokens were not parsed properly: %d, %d", s.ID, s.Status)
+	}
+	if s.Request != "-" || s.Agent != "curl" {
+		t.Errorf("quoted tokens were not parsed properly: %q, %q", s.Request, s.Agent)
+	}
+
+	if err = p.ParseLine(`19 [10/Oct/2000:13:55:36 -0700 "GET /" "curl" 200`, &s); err == nil {
+		t.Error("expected error about unterminated token, got nil")
+	}
+}
+
+func TestParseLineBareAfterBare(t *testing.T) {
+	var s struct {
+		Status  int    `hunk:"status"`
+		Bytes   uint64 `hunk:"bytes"`
+		Referer string `hunk:"referer"`
+		Agent   string `hunk:"agent"`
+	}
+
+	// bare token before the last one, which delimiters are missed
+	p, err := NewParser(`:status :bytes ":referer"`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine(`200 0 -`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Status != 200 || s.Bytes != 0 || s.Referer != "-" {
+		t.Errorf("unexpected result: %+v", s)
+	}
+	if err = p.ParseLine(`200 17 http://example.com/`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Bytes != 17 || s.Referer != "http://example.com/" {
+		t.Errorf("unexpected result: %+v", s)
+	}
+
+	// bare null marker in the middle
+	p, err = NewParser(`:status :bytes ":referer" ":agent"`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine(`404 0 - "curl/7.68.0"`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Status != 404 || s.Bytes != 0 || s.Referer != "-" || s.Agent != "curl/7.68.0" {
+		t.Errorf("unexpected result: %+v", s)
+	}
+}
+
+func TestParseLineEscapedQuotes(t *testing.T) {
+	var s struct {
+		Request    string `hunk:"request"`
+		RequestRaw string `hunk:"request_raw"`
+		Agent      string `hunk:"agent"`
+		Status     int    `hunk:"status"`
+	}
+
+	p, err := NewParser(`":request" ":agent" :status`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l := `"GET /?q=\"x\" HTTP/1.1" "Mozilla \x22beta\x22" 200`
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Request != `GET /?q=\"x\" HTTP/1.1` {
+		t.Errorf("escaped quote shifted token: %q", s.Request)
+	}
+	if s.Status != 200 {
+		t.Errorf("escaped quote shifted token: %d != %d", s.Status, 200)
+	}
+
+	p.SetUnescape(true)
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Request != `GET /?q="x" HTTP/1.1` {
+		t.Errorf("token was not unescaped: %q", s.Request)
+	}
+	if s.RequestRaw != `GET /?q=\"x\" HTTP/1.1` {
+		t.Errorf("raw token should be kept escaped: %q", s.RequestRaw)
+	}
+	if s.Agent != `Mozilla "beta"` {
+		t.Errorf("hex escapes were not unescaped: %q", s.Agent)
+	}
+}
+
+func TestParseLineTypeMismatch(t *testing.T) {
+	var s struct {
+		ID int `hunk:"id"`
+	}
+	var other struct {
+		ID int `hunk:"id"`
+		N  int `hunk:"n"`
+	}
+
+	p, err := NewParser(":id", &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, dest := range []interface{}{&other, s, nil} {
+		if err := p.ParseLine("1", dest); err != ErrTypeMismatch {
+			t.Errorf("%T: expected %s, got %v", dest, ErrTypeMismatch, err)
+		}
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	var s struct {
+		ID      int    `hunk:"id"`
+		Name    string `hunk:"name"`
+		Size    uint64 `hunk:"size"`
+		SizeRaw string `hunk:"size_raw"`
+	}
+
+	p, err := NewParser(`:id ":name" :size`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	buf := []byte(`42 "Gordon Freeman" 1337` + "\n")
+	if err = p.ParseBytes(buf, &s); err != nil {
+		t.Fatal(err)
+	}
+
+	// stored strings should not share memory with reused buffer
+	for i := range buf {
+		buf[i] = 'x'
+	}
+	if s.ID != 42 || s.Size != 1337 {
+		t.Errorf("unexpected numeric values: %d, %d", s.ID, s.Size)
+	}
+	if s.Name != "Gordon Freeman" || s.SizeRaw != "1337" {
+		t.Errorf("string values were affected by buffer reuse: %q, %q", s.Name, s.SizeRaw)
+	}
+
+	if err = p.ParseBytes(nil, &s); err != ErrEmptyLine {
+		t.Errorf("expected %s, got %v", ErrEmptyLine, err)
+	}
+
+	// token passed to parse function of custom type could be kept by it
+	var c struct {
+		ID hexID `hunk:"id"`
+	}
+	if p, err = NewParser(":id", &c); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var kept []string
+	p.RegisterType(reflect.TypeOf(hexID(0)), func(token string, dst reflect.Value) error {
+		kept = append(kept, token)
+		u, err := strconv.ParseUint(token, 16, 32)
+		dst.SetUint(u)
+		return err
+	})
+	buf = []byte("ff")
+	if err = p.ParseBytes(buf, &c); err != nil {
+		t.Fatal(err)
+	}
+	buf[0] = 'x'
+	if c.ID != 255 || len(kept) != 1 || kept[0] != "ff" {
+		t.Errorf("token kept by parse function was affected by buffer reuse: %d, %q", c.ID, kept)
+	}
+}
+
+func TestParseLinePointerFields(t *testing.T) {
+	var s struct {
+		Status *int64     `hunk:"status"`
+		Took   *float64   `hunk:"took"`
+		Date   *time.Time `hunk:"date"`
+		User   *string    `hunk:"user"`
+	}
+
+	p, err := NewParser(`:status :took [:date] ":user"`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetTimeLayout("date", "2006-01-02")
+
+	if err = p.ParseLine(`0 0.5 [2018-07-28] "gordon"`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Status == nil || *s.Status != 0 {
+		t.Errorf("status 0 should be populated, got %v", s.Status)
+	}
+	if s.Took == nil || *s.Took != 0.5 {
+		t.Errorf("took should be populated, got %v", s.Took)
+	}
+	if s.Date == nil || s.Date.Day() != 28 {
+		t.Errorf("date should be populated, got %v", s.Date)
+	}
+	if s.User == nil || *s.User != "gordon" {
+		t.Errorf("user should be populated, got %v", s.User)
+	}
+
+	if err = p.ParseLine(`- - [] ""`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Status != nil || s.Took != nil || s.Date != nil || s.User != nil {
+		t.Errorf("missing values should leave pointers nil, got %v %v %v %v", s.Status, s.Took, s.Date, s.User)
+	}
+}
+
+func TestSetNullValues(t *testing.T) {
+	var s struct {
+		Status int      `hunk:"status"`
+		Took   *float64 `hunk:"took"`
+		Host   string   `hunk:"host"`
+		Flag   bool     `hunk:"flag"`
+		Code   string   `hunk:"code"`
+	}
+
+	p, err := NewParser(`:status :took :host ":flag" :code`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetNullValues("null", "N/A", "")
+	p.SetFieldNullValues("code", "-")
+
+	if err = p.ParseLine(`200 0.5 example.com "true" 42`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.ParseLine(`null N/A null "" -`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Status != 0 {
+		t.Errorf("null should reset int field to zero, got %d", s.Status)
+	}
+	if s.Took != nil {
+		t.Errorf("null should leave pointer field nil, got %v", *s.Took)
+	}
+	if s.Host != "null" {
+		t.Errorf("string field should get null marker as is, got %q", s.Host)
+	}
+	if s.Flag {
+		t.Error("empty token should reset bool field to false")
+	}
+	if s.Code != "-" {
+		t.Errorf("string field should get null marker as is, got %q", s.Code)
+	}
+
+	// "-" is not a null marker for status anymore
+	if err = p.ParseLine(`- N/A null "" -`, &s); err == nil {
+		t.Error("expected error of parsing '-' into int, got nil")
+	}
+}
+
+func TestParseLineSQLNullFields(t *testing.T) {
+	var s struct {
+		Status sql.NullInt64    `hunk:"status"`
+		User   sql.NullString   `hunk:"user"`
+		Date   sql.NullTime     `hunk:"date"`
+		Took   sql.NullFloat64  `hunk:"took"`
+		Cached sql.NullBool     `hunk:"cached"`
+		Port   sql.Null[uint16] `hunk:"port"`
+	}
+
+	p, err := NewParser(`:status ":user" [:date] :took :cached :port`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetTimeLayout("date", "2006-01-02")
+
+	if err = p.ParseLine(`0 "gordon" [2018-07-28] 0.5 true 8080`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Status.Valid || s.Status.Int64 != 0 {
+		t.Errorf("status should be valid 0, got %+v", s.Status)
+	}
+	if !s.User.Valid || s.User.String != "gordon" {
+		t.Errorf("user should be valid, got %+v", s.User)
+	}
+	if !s.Date.Valid || s.Date.Time.Day() != 28 {
+		t.Errorf("date should be valid, got %+v", s.Date)
+	}
+	if !s.Took.Valid || s.Took.Float64 != 0.5 {
+		t.Errorf("took should be valid, got %+v", s.Took)
+	}
+	if !s.Cached.Valid || !s.Cached.Bool {
+		t.Errorf("cached should be valid, got %+v", s.Cached)
+	}
+	if !s.Port.Valid || s.Port.V != 8080 {
+		t.Errorf("port should be valid, got %+v", s.Port)
+	}
+
+	if err = p.ParseLine(`- "-" [-] - - -`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Status.Valid || s.User.Valid || s.Date.Valid || s.Took.Valid || s.Cached.Valid || s.Port.Valid {
+		t.Errorf("null markers should make fields invalid, got %+v", s)
+	}
+}
+
+func TestParseLineSQLNullMalformed(t *testing.T) {
+	var s struct {
+		Status sql.NullInt64 `hunk:"status"`
+	}
+
+	p, err := NewParser(`:status`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine(`200`, &s); err != nil || !s.Status.Valid {
+		t.Fatalf("unexpected result: %+v, %v", s, err)
+	}
+	if err = p.ParseLine(`OK`, &s); err == nil {
+		t.Fatal("expected error of parsing status, got nil")
+	}
+	if s.Status.Valid {
+		t.Errorf("malformed token should leave field invalid, got %+v", s.Status)
+	}
+}
+
+func TestParseLineSliceFields(t *testing.T) {
+	var s struct {
+		Addrs    []netip.AddrPort `hunk:"upstream_addr"`
+		Statuses []int            `hunk:"upstream_status"`
+		Times    []float64        `hunk:"upstream_response_time"`
+		Tags     []string         `hunk:"tags"`
+		Raw      string           `hunk:"upstream_addr_raw"`
+	}
+
+	p, err := NewParser(`":upstream_addr" ":upstream_status" ":upstream_response_time" :tags`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, tag := range []string{"upstream_addr", "upstream_status", "upstream_response_time"} {
+		p.SetListSeparator(tag, ",", " : ")
+	}
+	p.SetListSeparator("tags", "|")
+
+	l := `"10.0.0.1:80, 10.0.0.2:80 : 10.0.0.3:80" "502, 504 : 200" "0.001, - : 0.250" a|b|c`
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+
+	wantAddrs := []netip.AddrPort{
+		netip.MustParseAddrPort("10.0.0.1:80"),
+		netip.MustParseAddrPort("10.0.0.2:80"),
+		netip.MustParseAddrPort("10.0.0.3:80"),
+	}
+	if fmt.Sprint(s.Addrs) != fmt.Sprint(wantAddrs) {
+		t.Errorf("upstream_addr was not parsed properly:\nhave: %v\nwant: %v", s.Addrs, wantAddrs)
+	}
+	if s.Raw != "10.0.0.1:80, 10.0.0.2:80 : 10.0.0.3:80" {
+		t.Errorf("upstream_addr_raw was not set properly: %q", s.Raw)
+	}
+	if fmt.Sprint(s.Statuses) != "[502 504 200]" {
+		t.Errorf("upstream_status was not parsed properly: %v", s.Statuses)
+	}
+	if fmt.Sprint(s.Times) != "[0.001 0 0.25]" {
+		t.Errorf("upstream_response_time was not parsed properly: %v", s.Times)
+	}
+	if fmt.Sprint(s.Tags) != "[a b c]" {
+		t.Errorf("tags were not parsed properly: %v", s.Tags)
+	}
+
+	// shorter lists reuse slices, null marker resets them
+	if err = p.ParseLine(`"10.0.0.9:81" "-" "0.5" x`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Addrs) != 1 || s.Addrs[0].Port() != 81 || s.Statuses != nil || len(s.Times) != 1 || len(s.Tags) != 1 {
+		t.Errorf("unexpected lists after second line: %v %v %v %v", s.Addrs, s.Statuses, s.Times, s.Tags)
+	}
+
+	if err = p.ParseLine(`"10.0.0.1:80, bad" "200" "0.5" x`, &s); err == nil {
+		t.Error("expected error of parsing list element, got nil")
+	}
+
+	// empty separator would match forever
+	for _, args := range [][]string{{"tags"}, {"tags", ""}, {"tags", ",", ""}, {"upstream_addr_raw", ","}, {"missing", ","}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("SetListSeparator%q: expected panic", args)
+				}
+			}()
+			p.SetListSeparator(args[0], args[1:]...)
+		}()
+	}
+}
+
+func TestParseLineTagOptions(t *testing.T) {
+	var s struct {
+		Time   time.Time `hunk:"time_local,layout=02/Jan/2006:15:04:05 -0700"`
+		Date   time.Time `hunk:"date,layout=Mon, 02 Jan 2006,tz=UTC"`
+		Status int       `hunk:"status,default=200"`
+		Tags   []string  `hunk:"tags,sep=|"`
+		Cached bool      `hunk:"cached,omitempty"`
+	}
+
+	p, err := NewParser(`[:time_local] [:date] :status :tags ":cached"`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l := `[10/Oct/2000:13:55:36 -0700] [Tue, 10 Oct 2000] - a|b ""`
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+	if _, o := s.Time.Zone(); o != -7*3600 || s.Time.Minute() != 55 {
+		t.Errorf("time_local was not parsed with layout option: %s", s.Time)
+	}
+	if s.Date.Location() != time.UTC || s.Date.Day() != 10 {
+		t.Errorf("date was not parsed with layout and tz options: %s", s.Date)
+	}
+	if s.Status != 200 {
+		t.Errorf("status should get default value, got %d", s.Status)
+	}
+	if len(s.Tags) != 2 || s.Tags[1] != "b" {
+		t.Errorf("tags were not split with sep option: %v", s.Tags)
+	}
+
+	var bad struct {
+		Status int `hunk:"status,layout=2006"`
+	}
+	if _, err = NewParser(":status", &bad); !errors.Is(err, ErrUnknownOption) {
+		t.Errorf("expected %s, got %v", ErrUnknownOption, err)
+	}
+}
+
+type CommonHTTP struct {
+	Method string `hunk:"method"`
+	Status int    `hunk:"status"`
+}
+
+type upstream struct {
+	Addr    netip.AddrPort `hunk:"addr"`
+	Time    float64        `hunk:"time"`
+	TimeRaw string         `hunk:"time_raw"`
+}
+
+func TestParseLineNestedStructs(t *testing.T) {
+	var s struct {
+		CommonHTTP
+		Upstream upstream  `hunk:"upstream"`
+		Cache    *upstream `hunk:"cache"`
+		Client   struct {
+			IP net.IP `hunk:"ip"`
+		} `hunk:",prefix=client_"`
+	}
+
+	p, err := NewParser(":method :status :upstream.addr :upstream.time :cache.addr :client_ip", &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err = p.ParseLine("GET 200 10.0.0.1:80 0.25 10.0.0.2:8080 192.168.1.1", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Method != "GET" || s.Status != 200 {
+		t.Errorf("fields of embedded struct were not parsed: %+v", s.CommonHTTP)
+	}
+	if s.Upstream.Addr.Port() != 80 || s.Upstream.Time != 0.25 || s.Upstream.TimeRaw != "0.25" {
+		t.Errorf("fields of nested struct were not parsed: %+v", s.Upstream)
+	}
+	if s.Cache == nil || s.Cache.Addr.Port() != 8080 {
+		t.Errorf("nested struct pointer was not allocated: %+v", s.Cache)
+	}
+	if s.Client.IP.String() != "192.168.1.1" {
+		t.Errorf("field of prefixed struct was not parsed: %s", s.Client.IP)
+	}
+
+	var embedded struct {
+		*CommonHTTP
+	}
+	p, err = NewParser(":method :status", &embedded)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine("POST 201", &embedded); err != nil {
+		t.Fatal(err)
+	}
+	if embedded.CommonHTTP == nil || embedded.Method != "POST" || embedded.Status != 201 {
+		t.Errorf("embedded struct pointer was not allocated: %+v", embedded.CommonHTTP)
+	}
+
+	var bad struct {
+		Status int `hunk:"status,prefix=s_"`
+	}
+	if _, err = NewParser(":status", &bad); !errors.Is(err, ErrUnknownOption) {
+		t.Errorf("expected %s, got %v", ErrUnknownOption, err)
+	}
+}
+
+func TestSetResetFields(t *testing.T) {
+	var s struct {
+		ID       int       `hunk:"id"`
+		Bytes    uint64    `hunk:"bytes"`
+		BytesRaw string    `hunk:"bytes_raw"`
+		Cost     float64   `hunk:"cost"`
+		Cache    *upstream `hunk:"cache"`
+	}
+
+	p, err := NewParser(":id :bytes", &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetResetFields(true)
+
+	// field absent in format is not reset
+	s.Cost = 1.5
+	if err = p.ParseLine("1 1024", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cost != 1.5 || s.Bytes != 1024 || s.BytesRaw != "1024" {
+		t.Errorf("unexpected result: %+v", s)
+	}
+	if s.Cache != nil {
+		t.Errorf("nil nested struct should not be allocated: %+v", s.Cache)
+	}
+
+	if err = p.ParseLine("2 big", &s); err == nil {
+		t.Fatal("expected error of parsing bytes, got nil")
+	}
+	if s.ID != 2 || s.Bytes != 0 || s.BytesRaw != "big" {
+		t.Errorf("values of previous line should be reset: %+v", s)
+	}
+
+	if err = p.ParseLine("# comment", &s); err != nil || s.ID != 2 {
+		t.Errorf("commented line should not reset fields: %+v, %v", s, err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		p.ParseLine("3 2048", &s)
+	})
+	if allocs != 0 {
+		t.Errorf("reset should not allocate, got %.0f allocs", allocs)
+	}
+}
+
+func TestNewParserFromStruct(t *testing.T) {
+	type entry struct {
+		CommonHTTP
+		Upstream upstream  `hunk:"upstream"`
+		Name     string    `hunk:"name"`
+		Date     time.Time `hunk:"date,layout=2006-01-02"`
+		Comment  string
+	}
+
+	var s entry
+	p, err := NewParserFromStruct(&s, '|')
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fmt.Sprint(p.mapper.tokensSeq) != "[method status upstream.addr upstream.time name date]" {
+		t.Fatalf("unexpected tokens order: %v", p.mapper.tokensSeq)
+	}
+
+	if err = p.ParseLine("GET|200|10.0.0.1:80|0.25|Gordon Freeman|2018-07-28", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Method != "GET" || s.Upstream.Time != 0.25 || s.Upstream.TimeRaw != "0.25" || s.Date.Day() != 28 || s.Name != "Gordon Freeman" {
+		t.Errorf("unexpected result: %+v", s)
+	}
+
+	l, err := p.FormatLine(&s)
+	if err != nil || l != "GET|200|10.0.0.1:80|0.25|Gordon Freeman|2018-07-28" {
+		t.Errorf("unexpected line %q, %v", l, err)
+	}
+
+	// tag of _raw field without typed one is kept in format
+	type rawOnly struct {
+		ID       int    `hunk:"id"`
+		TokenRaw string `hunk:"token_raw"`
+		Name     string `hunk:"name"`
+	}
+	r := rawOnly{ID: 17, TokenRaw: "abc", Name: "Gordon"}
+	if p, err = NewParserFromStruct(&r, ' '); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fmt.Sprint(p.mapper.tokensSeq) != "[id token name]" {
+		t.Fatalf("unexpected tokens order: %v", p.mapper.tokensSeq)
+	}
+	if l, err = p.FormatLine(&r); err != nil || l != "17 abc Gordon" {
+		t.Errorf("unexpected line %q, %v", l, err)
+	}
+	var dst rawOnly
+	if err = p.ParseLine(l, &dst); err != nil || dst != r {
+		t.Errorf("round trip failed: %+v, %v", dst, err)
+	}
+
+	var untagged struct {
+		Name string
+	}
+	if _, err = NewParserFromStruct(&untagged, ' '); err != ErrNotSpecified {
+		t.Errorf("expected %s, got %v", ErrNotSpecified, err)
+	}
+}
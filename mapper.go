@@ -1,12 +1,15 @@
 package hunkee
 
 import (
-	"bytes"
+	"encoding"
 	"fmt"
 	"log"
 	"net"
+	"net/netip"
 	"net/url"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 	"unicode"
 )
@@ -17,15 +20,36 @@ type mapper struct {
 	// no mutexes because we write to fields and tokenSeq
 	// only once when building up structure
 	fields       map[string]*field
+	typ          reflect.Type // type of structure mapper was built for, nil for maps
 	tokensSeq    []string
-	tokenSep     byte   // byte which stead before and right after each token
-	comPrefix    string // skip line if line has such prefix
-	prefixActive bool   // if false, prefix check will be disabled
+	params       []*namedParameter // compiled format, same order as tokensSeq
+	tokenSep     byte              // byte which stead before and right after each token
+	comPrefix    string            // skip line if line has such prefix
+	prefixActive bool              // if false, prefix check will be disabled
+	unescape     bool              // unescape tokens before processing
+	nulls        []string          // tokens which stand for missing value
+	errLineLimit int               // max length of line kept by ParseError, 0 for no limit
+	errMode      ErrorMode         // how to handle fields which could not be parsed
+	resets       []*field          // fields zeroed before parsing line, nil if disabled
 	workerPool   *pool
 }
 
 type fieldType int
 
+// nameTerminators is a set of literal symbols which could be placed
+// in format string right after token name
+const nameTerminators = "\"'`[](){}<>,;|=/\\!?@#&*+%~^$"
+
+// delimiters maps opening delimiter of token to the closing one
+var delimiters = map[byte]byte{
+	'"':  '"',
+	'\'': '\'',
+	'[':  ']',
+	'(':  ')',
+	'{':  '}',
+	'<':  '>',
+}
+
 const (
 	typeIgnored fieldType = 1 << iota
 	typeBool
@@ -37,6 +61,12 @@ const (
 	typeDuration
 	typeURL
 	typeTime
+	typeAddr            // netip.Addr
+	typeAddrPort        // netip.AddrPort
+	typePrefix          // netip.Prefix
+	typeUnmarshaler     // implements Unmarshaler
+	typeTextUnmarshaler // implements encoding.TextUnmarshaler
+	typeRegistered      // parsed by registered ParseFunc
 )
 
 // field represents structure field
@@ -44,30 +74,111 @@ type field struct {
 	index        []int
 	ftype        fieldType
 	reflectType  reflect.Type // field Go type
-	reflectKind  reflect.Kind
-	reflectValue reflect.Value
-	name         string // field key
-	hasRaw       bool   // signals that corresponded field has raw field too
-	position     int    // numeric position of token in format string
+	reflectKind  reflect.Kind // kind of field or value it points to
+	pointer      bool         // field is a pointer, nil for missing values
+	sqlNull      bool         // field is sql.Null*, invalid for missing values
+	name         string       // field key
+	hasRaw       bool         // signals that corresponded field has raw field too
+	position     int          // numeric position of token in format string
 	timeOptions  *TimeOption
+	durationUnit time.Duration // unit of plain numeric tokens for time.Duration
+	parse        ParseFunc     // parse function of registered type
+	nulls        []string      // overrides null markers of mapper if not nil
+	def          string        // token used instead of null or empty one
+	hasDefault   bool          // def is set
+	omitEmpty    bool          // empty token is a missing value
+	elem         *field        // element of slice field
+	listSeps     []string      // separators of slice field elements
+	layoutSet    bool          // time layout is set explicitly
+	shadowed     [][]int       // indexes of other fields with the same tag
+}
+
+// setLayout sets time layout of field
+func (f *field) setLayout(layout string) {
+	f.timeOptions.Layout = layout
+	f.layoutSet = true
+}
+
+// custom reports whether field parses token itself
+// or with registered function
+func (f *field) custom() bool {
+	return f.ftype == typeUnmarshaler || f.ftype == typeTextUnmarshaler || f.ftype == typeRegistered
+}
+
+// retainsToken reports whether token or its part could be kept by field
+// value after processing, e.g. as string or url.URL. Parse methods and
+// functions of custom types could keep it regardless of kind.
+func (f *field) retainsToken() bool {
+	if f.hasRaw || f.custom() {
+		return true
+	}
+	if f.elem != nil {
+		return f.elem.retainsToken()
+	}
+	switch f.reflectKind {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return false
+	}
+	return f.ftype != typeIP
 }
 
 type namedParameter struct {
 	name   string // entry name without ':' (tag)
 	strPos int    // numeric position in format string
+	lead   string // literal text which should be matched right before token
+	stop   string // literal text which terminates token (lead of the next one)
+	last   bool   // token is the last one in format string
+	open   byte   // delimiter right before token, 0 if token is bare
+	close  byte   // delimiter right after token
+	next   *namedParameter
+}
+
+// ends reports whether bare token described by p could end right before s
+func (p *namedParameter) ends(s string) bool {
+	switch {
+	case p.last && p.stop == "":
+		return s == ""
+	case strings.HasPrefix(s, p.stop):
+		return true
+	}
+	// delimiters of the next token are optional
+	return p.next != nil && p.next.open != 0 && p.next.lead != "" && strings.HasPrefix(s, p.next.lead)
 }
 
-func initMapper(format string, to interface{}) (*mapper, error) {
+func initMapper(format string, to interface{}, types *registry) (*mapper, error) {
 	// get info about entry
 	tokens, err := extractNames(format)
 	if err != nil {
 		return nil, err
 	}
-	fields, err := extractFieldsOnTags(to)
+	return buildMapper(tokens, to, types)
+}
+
+// buildMapper binds compiled format tokens to the fields of passed structure.
+// Fields of types registered in types are parsed by registered functions.
+func buildMapper(tokens []*namedParameter, to interface{}, types *registry) (*mapper, error) {
+	// no structure: tokens are collected into map
+	if to == nil {
+		fields, err := schemaFields(tokens, nil, types)
+		if err != nil {
+			return nil, err
+		}
+		return bindMapper(tokens, fields, nil)
+	}
+
+	fields, err := extractFieldsOnTags(to, types)
 	if err != nil {
 		return nil, err
 	}
+	return bindMapper(tokens, fields, deref(reflect.TypeOf(to)))
+}
 
+// bindMapper binds compiled format tokens to the fields of structure of
+// type typ or to the fields of map if typ is nil
+func bindMapper(tokens []*namedParameter, fields map[string]*field, typ reflect.Type) (*mapper, error) {
 	tokenSeq := make([]string, len(tokens))
 	for i := 0; i < len(tokens); i++ {
 		tokenSeq[i] = tokens[i].name
@@ -83,11 +194,17 @@ func initMapper(format string, to interface{}) (*mapper, error) {
 		}
 		fields[tokens[i].name].position = tokens[i].strPos
 		fields[tokens[i].name].name = tokens[i].name
+		if elem := fields[tokens[i].name].elem; elem != nil {
+			elem.name = tokens[i].name
+		}
 	}
 
 	return &mapper{
 		fields:     fields,
+		typ:        typ,
 		tokensSeq:  tokenSeq,
+		params:     tokens,
+		nulls:      []string{"-"},
 		workerPool: initPool(10),
 	}, nil
 }
@@ -113,6 +230,20 @@ func (m *mapper) raw(normal *field) *field {
 	return f
 }
 
+// isNull reports whether token is a null marker of field
+func (m *mapper) isNull(f *field, token string) bool {
+	nulls := m.nulls
+	if f.nulls != nil {
+		nulls = f.nulls
+	}
+	for i := 0; i < len(nulls); i++ {
+		if token == nulls[i] {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *mapper) getField(tag string) *field {
 	return m.fields[tag]
 }
@@ -131,13 +262,87 @@ func determineType(v interface{}) (ftype fieldType) {
 		ftype = typeIP
 	case url.URL, *url.URL:
 		ftype = typeURL
+	case netip.Addr:
+		ftype = typeAddr
+	case netip.AddrPort:
+		ftype = typeAddrPort
+	case netip.Prefix:
+		ftype = typePrefix
 	default:
 		ftype = -1
 	}
 	return
 }
 
-func extractFieldsOnTags(arg interface{}) (map[string]*field, error) {
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// determineCustomType checks if type (or pointer to it) could parse token itself
+func determineCustomType(t reflect.Type) fieldType {
+	pt := reflect.PointerTo(deref(t))
+	switch {
+	case pt.Implements(unmarshalerType):
+		return typeUnmarshaler
+	case pt.Implements(textUnmarshalerType):
+		return typeTextUnmarshaler
+	}
+	return -1
+}
+
+// newField describes field of type t. Pointer fields and sql.Null* fields
+// are described by type of value they hold, slice fields have description
+// of their element.
+func newField(t reflect.Type, types *registry) *field {
+	elem := deref(t)
+	sqlNull := isSQLNull(elem) && types.lookup(elem) == nil
+	if sqlNull {
+		elem = elem.Field(0).Type
+	}
+	ftype := determineType(reflect.Zero(elem).Interface())
+	if ftype < 0 {
+		ftype = determineCustomType(elem)
+	}
+
+	f := &field{
+		ftype:       ftype,
+		reflectType: t,
+		reflectKind: elem.Kind(),
+		pointer:     t.Kind() == reflect.Ptr,
+		sqlNull:     sqlNull,
+	}
+
+	// registered types take precedence over built-in ones
+	if fn := types.lookup(elem); fn != nil {
+		f.ftype = typeRegistered
+		f.parse = fn
+	}
+
+	if f.ftype == typeTime {
+		f.timeOptions = DefaultTimeOptions()
+	}
+
+	// byte slices are not lists
+	if f.reflectKind == reflect.Slice && f.ftype < 0 && elem.Elem().Kind() != reflect.Uint8 {
+		f.elem = newField(elem.Elem(), types)
+		f.listSeps = []string{","}
+		// time layout of list is a time layout of its elements
+		f.timeOptions = f.elem.timeOptions
+	}
+	return f
+}
+
+// isSQLNull reports whether t is one of database/sql Null types:
+// sql.NullInt64, sql.NullString, sql.Null[T] and so on. All of them
+// hold value in the first field and validity flag in the second one.
+func isSQLNull(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.PkgPath() == "database/sql" &&
+		strings.HasPrefix(t.Name(), "Null") && t.NumField() == 2 &&
+		t.Field(1).Name == "Valid" && t.Field(1).Type.Kind() == reflect.Bool
+}
+
+func extractFieldsOnTags(arg interface{}, types *registry) (map[string]*field, error) {
 	v := reflect.ValueOf(arg)
 
 	// Maybe it's a pointer to struct - get it's value
@@ -151,36 +356,90 @@ func extractFieldsOnTags(arg interface{}) (map[string]*field, error) {
 	}
 
 	index := make(map[string]*field)
+	if !v.CanSet() {
+		return index, nil
+	}
+
+	if err := collectFields(index, v.Type(), nil, "", nil, types); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
 
-	for i := 0; i < v.NumField(); i++ {
-		f := v.Type().Field(i)
+// collectFields indexes tagged fields of struct type t. path is an index
+// of t in the root structure and prefix is prepended to all tags of t.
+// Embedded structs are walked with the same prefix, so their fields are
+// promoted. Nested structs are walked with "tag." prefix or the one set
+// by prefix option. parents holds types being walked to catch recursion.
+// Registered types are not walked.
+func collectFields(index map[string]*field, t reflect.Type, path []int, prefix string, parents []reflect.Type, types *registry) error {
+	for _, p := range parents {
+		if p == t {
+			return fmt.Errorf("%w: recursive struct %s", ErrSyntax, t)
+		}
+	}
+	parents = append(parents, t)
 
-		val := v.FieldByIndex(f.Index)
-		// Ignore anonymous and unexported fields
-		if f.Anonymous || !v.CanSet() || !val.CanInterface() {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		// Ignore unexported fields, but walk into unexported embedded
+		// structs since their exported fields are settable
+		embedded := f.Anonymous && nested(f.Type, types)
+		if !f.IsExported() && !(embedded && f.Type.Kind() == reflect.Struct) {
 			continue
 		}
 
-		tag, normalizedTag, err := processTag(f.Tag)
+		fieldPath := make([]int, len(path)+1)
+		copy(fieldPath, path)
+		fieldPath[len(path)] = i
+
+		tag, normalizedTag, opts, err := processTag(f.Tag)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+
+		walk := tag != unexportedTag || opts != nil && opts.prefix != nil ||
+			embedded && f.Tag.Get(libtag) != unexportedTag
+		if nested(f.Type, types) && walk {
+			inner := prefix
+			switch {
+			case opts != nil && opts.prefix != nil:
+				inner += *opts.prefix
+			case tag != unexportedTag:
+				inner += tag + "."
+			}
+			if err = collectFields(index, deref(f.Type), fieldPath, inner, parents, types); err != nil {
+				return fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			continue
+		}
+		if opts != nil && opts.prefix != nil {
+			return fmt.Errorf("field %s: %w: prefix option is applicable to struct fields only", f.Name, ErrUnknownOption)
+		}
+		if tag == unexportedTag {
+			continue
+		}
+
+		tag = prefix + tag
+		if normalizedTag != "" {
+			normalizedTag = prefix + normalizedTag
 		}
 
 		// Check if field already indexed
-		if _, ok := index[tag]; ok {
-			index[tag].index = f.Index
+		if prev, ok := index[tag]; ok && prev.reflectType != nil {
+			prev.shadowed = append(prev.shadowed, prev.index)
+			prev.index = fieldPath
 		} else {
-			ftype := determineType(val.Interface())
-			index[tag] = &field{
-				index:        f.Index,
-				ftype:        ftype,
-				reflectValue: val,
-				reflectType:  f.Type,
-				reflectKind:  f.Type.Kind(),
-			}
+			index[tag] = newField(f.Type, types)
+			index[tag].index = fieldPath
+			// field could be registered by its _raw companion
+			index[tag].hasRaw = ok && prev.hasRaw
+		}
 
-			if ftype == typeTime {
-				index[tag].timeOptions = DefaultTimeOptions()
+		if opts != nil {
+			if err = opts.apply(index[tag]); err != nil {
+				return fmt.Errorf("field %s: %w", f.Name, err)
 			}
 		}
 
@@ -193,78 +452,174 @@ func extractFieldsOnTags(arg interface{}) (map[string]*field, error) {
 			}
 		}
 	}
-	return index, nil
+	return nil
 }
 
-func extractNames(format string) ([]*namedParameter, error) {
-	var (
-		valid = "_0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-		names = make([]*namedParameter, 0)
+// nested reports whether t is a structure (or pointer to it) with fields
+// to be mapped on their own rather than a value parsed from single token.
+func nested(t reflect.Type, types *registry) bool {
+	elem := deref(t)
+	if elem.Kind() != reflect.Struct || isSQLNull(elem) || types.lookup(elem) != nil {
+		return false
+	}
+	return determineType(reflect.Zero(elem).Interface()) < 0 && determineCustomType(elem) < 0
+}
 
-		s      = []byte(format)
-		pos    int
-		inName bool
-		name   string
-	)
+// structNames compiles format of tags of passed structure in order of
+// field declaration separated by sep (space if zero). _raw fields are skipped,
+// unless there is no typed field of their tag, then token of the tag is
+// placed at the position of _raw field.
+func structNames(to interface{}, sep byte) ([]*namedParameter, error) {
+	if to == nil {
+		return nil, ErrOnlyStructs
+	}
+	fields, err := extractFieldsOnTags(to, defaultRegistry)
+	if err != nil {
+		return nil, err
+	}
 
-	for i := 0; i < len(s); i++ {
-		if !inName {
-			switch s[i] {
-			case ':':
-				inName = true
-			case '-': // ignore field
-				names = append(names, &namedParameter{
-					name: "-", strPos: pos,
-				})
-				name = ""
-				pos++
-			}
+	positions := make(map[string][]int, len(fields))
+	for tag, f := range fields {
+		// skip placeholders of raw fields
+		if f.index == nil {
 			continue
 		}
-
-		if inName {
-			if s[i] == ':' {
-				// another one ':' and currently in word - error
-				return nil, ErrUnexpectedColon
+		if name := strings.TrimSuffix(tag, "_raw"); name != tag {
+			if normal, ok := fields[name]; ok && normal.hasRaw {
+				if normal.index == nil {
+					positions[name] = f.index
+				}
+				continue
 			}
+		}
+		positions[tag] = f.index
+	}
+	if len(positions) == 0 {
+		return nil, ErrNotSpecified
+	}
 
-			if unicode.IsSpace(rune(s[i])) {
-				names = append(names, &namedParameter{
-					name: name, strPos: pos,
-				})
+	tags := make([]string, 0, len(positions))
+	for tag := range positions {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return lessIndex(positions[tags[i]], positions[tags[j]])
+	})
 
-				if debug {
-					log.Printf("Field %q: %+v\n", name, names[len(names)-1])
-				}
+	if sep == 0 {
+		sep = ' '
+	}
+	names := make([]*namedParameter, len(tags))
+	for i, tag := range tags {
+		names[i] = &namedParameter{name: tag, strPos: i}
+		if i > 0 {
+			names[i].lead = string(sep)
+		}
+	}
+	compileParams(names, "")
+	return names, nil
+}
 
-				inName = false
-				name = ""
-				pos++
-				continue
-			}
+// extractNames compiles format string into sequence of named parameters.
+// Everything between ':name' tokens is treated as literal text, which
+// should be present in parsed line as is. Single '-' surrounded by
+// non-name symbols is a placeholder for ignored token. Quotes or brackets
+// wrapping a token become delimiters of that token.
+func extractNames(format string) ([]*namedParameter, error) {
+	var (
+		names   = make([]*namedParameter, 0)
+		s       = strings.TrimRight(format, "\r\n")
+		literal []byte
+	)
 
-			if !bytes.ContainsAny(s[i:i+1], valid) && s[i] != '\n' {
-				return nil,
-					fmt.Errorf("'%s': unsupported symbol %q in format string at pos %d", s, s[i], i)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == ':' && i+1 < len(s) && isNameSymbol(s[i+1]):
+			j := i + 1
+			// dots separate names of nested structure fields
+			for j < len(s) && (isNameSymbol(s[j]) || s[j] == '.' && j+1 < len(s) && isNameSymbol(s[j+1])) {
+				j++
 			}
-
-			// last symbol
-			if i == len(s)-1 {
-				if s[i] != '\n' {
-					name += string(s[i])
+			if j < len(s) && !isNameTerminator(s[j]) {
+				if s[j] == ':' {
+					// another one ':' and currently in word - error
+					return nil, ErrUnexpectedColon
 				}
-				names = append(names, &namedParameter{
-					name: name, strPos: pos,
-				})
-				break
+				return nil,
+					fmt.Errorf("'%s': unsupported symbol %q in format string at pos %d", s, s[j], j)
 			}
 
-			name += string(s[i])
+			names = append(names, &namedParameter{
+				name: s[i+1 : j], strPos: len(names), lead: string(literal),
+			})
+			literal = literal[:0]
+			i = j - 1
+
+			if debug {
+				log.Printf("Field %q: %+v\n", names[len(names)-1].name, names[len(names)-1])
+			}
+		case s[i] == '-' && (i == 0 || !isNameSymbol(s[i-1])) &&
+			(i == len(s)-1 || !isNameSymbol(s[i+1]) && s[i+1] != '-'):
+			// ignore field
+			names = append(names, &namedParameter{
+				name: "-", strPos: len(names), lead: string(literal),
+			})
+			literal = literal[:0]
+		default:
+			literal = append(literal, s[i])
 		}
 	}
 
+	compileParams(names, string(literal))
+
 	if debug {
 		log.Println("format string has been successfully parsed")
 	}
 	return names, nil
 }
+
+// compileParams detects delimiters of each token and literal text
+// which terminates it. trail is literal text after the last token.
+func compileParams(names []*namedParameter, trail string) {
+	for i := 0; i < len(names); i++ {
+		next := &trail
+		if i < len(names)-1 {
+			next = &names[i+1].lead
+		}
+
+		// token enclosed in delimiters like "token" or [token] carries
+		// them on its own instead of leaving them to literal text
+		if l := len(names[i].lead); l > 0 {
+			if c, ok := delimiters[names[i].lead[l-1]]; ok && strings.IndexByte(*next, c) == 0 {
+				names[i].open, names[i].close = names[i].lead[l-1], c
+				names[i].lead = names[i].lead[:l-1]
+				*next = (*next)[1:]
+			}
+		}
+	}
+
+	for i := 0; i < len(names); i++ {
+		if i == len(names)-1 {
+			names[i].stop = trail
+			names[i].last = true
+			break
+		}
+		names[i].stop = names[i+1].lead
+		names[i].next = names[i+1]
+		if names[i+1].open != 0 {
+			names[i].stop += string(names[i+1].open)
+		}
+	}
+}
+
+// isNameSymbol reports whether c could be used in token name
+func isNameSymbol(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// isNameTerminator reports whether c could be placed right after token name.
+// Dots and dashes are not allowed to avoid ambiguity with name itself:
+// dot is a part of name if followed by name symbol.
+func isNameTerminator(c byte) bool {
+	return unicode.IsSpace(rune(c)) || strings.IndexByte(nameTerminators, c) >= 0
+}
@@ -73,30 +73,35 @@ func TestInitMapper(t *testing.T) {
 		emf  = ":so_what :in_time :arrival :token :ticket_id"
 		rawf = ":size"
 
-		badWithPoint = ":id :name.name :added"
+		emNested     = ":so_what :st.in_time :st.arrival :st.token :st.ticket_id"
+		badWithPoint = ":id :name. :added"
 	)
 
-	_, err := initMapper(tef, &te)
+	_, err := initMapper(tef, &te, defaultRegistry)
 	if err != nil {
 		t.Fatalf("Mapper initialization over %q should be finished without error, but have: %s", tef, err)
 	}
-	_, err = initMapper(badWithPoint, &te)
+	_, err = initMapper(badWithPoint, &te, defaultRegistry)
 	if err == nil {
 		t.Fatalf("Mapper initialization over %q should be finished with error of unexpected symbol, but no error occured", badWithPoint)
 	}
-	_, err = initMapper(ef, &e)
+	_, err = initMapper(ef, &e, defaultRegistry)
 	if err != nil {
 		t.Fatalf("Unexpected error %s", err)
 	}
-	_, err = initMapper(nsef, &nse)
+	_, err = initMapper(nsef, &nse, defaultRegistry)
 	if err == nil {
 		t.Fatal("expected error about absence of filed 'fail_with_it', got nil")
 	}
-	_, err = initMapper(emf, &em)
+	_, err = initMapper(emf, &em, defaultRegistry)
 	if err == nil {
 		t.Fatalf("Unexpected successfull finish of maper initialization")
 	}
-	_, err = initMapper(rawf, &r)
+	_, err = initMapper(emNested, &em, defaultRegistry)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	_, err = initMapper(rawf, &r, defaultRegistry)
 	if err != nil {
 		t.Fatalf("unexpected error %s", err)
 	}
@@ -107,12 +112,12 @@ func TestExtractNames(t *testing.T) {
 
 	// valid formats
 	a := ":id :temp :token :ip :nice :ch :date :dur :explicit_url :ignore_it :fail_with_it"
-	b := ":so_what :in_time :starrival :token :ticket_id"
+	b := ":so_what :in_time :starrival :token :ticket_id :upstream.addr"
 	// c := `":id" ":temp" ":token" ":ip" ":nice" ":ch" ":date" ":dur" ":explicit_url" ":ignore_it" ":fail_with_it"`
 
 	// invalid formats
 	ia := ":id :temp :token :ip :nice :en:e"
-	ib := ":id :temp.far :token :ip :nice :en:e"
+	ib := ":id :temp. :token :ip :nice :en:e"
 	ic := ":so-what :ticket-id"
 
 	// case A
@@ -132,10 +137,13 @@ func TestExtractNames(t *testing.T) {
 		t.Fatalf("unexpected error: %s", err)
 	}
 
-	lb := 5
+	lb := 6
 	if len(p) != lb {
 		t.Fatalf("wrong length or extracted names: %d elements instead of %d", len(p), lb)
 	}
+	if p[5].name != "upstream.addr" {
+		t.Fatalf("wrong name of nested field: %q", p[5].name)
+	}
 
 	// case IA (invalid A)
 	expErr := "unexpected"
@@ -162,6 +170,36 @@ func TestExtractNames(t *testing.T) {
 	}
 }
 
+func TestExtractNamesLiterals(t *testing.T) {
+	t.Parallel()
+
+	f := `[:time] - ":request" :status, id=:id;`
+	p, err := extractNames(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []namedParameter{
+		{name: "time", stop: " ", open: '[', close: ']'},
+		{name: "-", lead: " ", stop: ` "`},
+		{name: "request", lead: " ", stop: " ", open: '"', close: '"'},
+		{name: "status", lead: " ", stop: ", id="},
+		{name: "id", lead: ", id=", stop: ";", last: true},
+	}
+	if len(p) != len(want) {
+		t.Fatalf("%q - wrong length or extracted names: %d elements instead of %d", f, len(p), len(want))
+	}
+	for i := 0; i < len(want); i++ {
+		want[i].strPos = i
+		if i < len(want)-1 {
+			want[i].next = p[i+1]
+		}
+		if *p[i] != want[i] {
+			t.Errorf("%q - unexpected parameter #%d:\nhave: %+v\nwant: %+v", f, i, *p[i], want[i])
+		}
+	}
+}
+
 func TestExtractFieldsOnTags(t *testing.T) {
 	type (
 		notSoEasy struct {
@@ -193,7 +231,7 @@ func TestExtractFieldsOnTags(t *testing.T) {
 		wr  withReader
 	)
 
-	f, err := extractFieldsOnTags(nse)
+	f, err := extractFieldsOnTags(nse, defaultRegistry)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -215,13 +253,13 @@ func TestExtractFieldsOnTags(t *testing.T) {
 		}
 	}
 
-	_, err = extractFieldsOnTags(wr)
+	_, err = extractFieldsOnTags(wr, defaultRegistry)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
 
 	var abc interface{}
-	_, err = extractFieldsOnTags(abc)
+	_, err = extractFieldsOnTags(abc, defaultRegistry)
 	if err == nil {
 		t.Fatalf("expected %s have nil error", ErrOnlyStructs)
 	}
@@ -238,7 +276,7 @@ func TestMapperRaw(t *testing.T) {
 	}
 
 	tef := ":id :name :added"
-	m, err := initMapper(tef, &te)
+	m, err := initMapper(tef, &te, defaultRegistry)
 	if err != nil {
 		t.Fatalf("Mapper initialization over %q should be finished without error, but have: %s", tef, err)
 	}
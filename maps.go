@@ -0,0 +1,124 @@
+package hunkee
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// Kind is a type of value in schema of map parser
+type Kind int
+
+const (
+	KindString   Kind = iota // string
+	KindBool                 // bool
+	KindInt                  // int64
+	KindUint                 // uint64
+	KindFloat                // float64
+	KindTime                 // time.Time, RFC3339 by default
+	KindDuration             // time.Duration
+	KindIP                   // net.IP
+	KindURL                  // *url.URL
+)
+
+// kindTypes maps schema kinds to the Go types of map values
+var kindTypes = map[Kind]reflect.Type{
+	KindString:   reflect.TypeOf(""),
+	KindBool:     reflect.TypeOf(false),
+	KindInt:      reflect.TypeOf(int64(0)),
+	KindUint:     reflect.TypeOf(uint64(0)),
+	KindFloat:    reflect.TypeOf(float64(0)),
+	KindTime:     reflect.TypeOf(time.Time{}),
+	KindDuration: reflect.TypeOf(time.Duration(0)),
+	KindIP:       reflect.TypeOf(net.IP{}),
+	KindURL:      reflect.TypeOf(&url.URL{}),
+}
+
+// NewSchemaParser creates parser which parses lines into map[string]any
+// with ParseLineAny. Each token is parsed into type of its kind in schema,
+// tokens absent in schema are kept as strings. Options are applied in order.
+func NewSchemaParser(format string, schema map[string]Kind, opts ...ParserOption) (*Parser, error) {
+	tokens, err := extractNames(format)
+	if err != nil {
+		return nil, err
+	}
+	return newParserWith(opts, func(types *registry) (*mapper, error) {
+		fields, err := schemaFields(tokens, schema, types)
+		if err != nil {
+			return nil, err
+		}
+		return bindMapper(tokens, fields, nil)
+	})
+}
+
+// schemaFields describes map fields of format tokens by their kinds in schema
+func schemaFields(tokens []*namedParameter, schema map[string]Kind, types *registry) (map[string]*field, error) {
+	fields := make(map[string]*field, len(tokens))
+	for _, token := range tokens {
+		if token.name == "-" {
+			continue
+		}
+		t, ok := kindTypes[schema[token.name]]
+		if !ok {
+			return nil, fmt.Errorf("tag %q: %w: kind %d", token.name, ErrNotSupportedType, schema[token.name])
+		}
+		fields[token.name] = newField(t, types)
+	}
+	return fields, nil
+}
+
+// ParseLineMap parses line into map of tag -> token. Tokens are unescaped
+// if parser is set up to, null markers are kept as is. Parser could be
+// created for any structure or for nil one. Returns nil map for commented line.
+func (p *Parser) ParseLineMap(line string) (map[string]string, error) {
+	var values map[string]string
+	err := p.tokenize(line, func(field *field, token string) error {
+		if values == nil {
+			values = make(map[string]string, len(p.mapper.tokensSeq))
+		}
+		values[field.name] = p.mapper.prepareToken(field, token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ParseLineAny parses line into map of tag -> value of type declared in
+// schema passed to NewSchemaParser. Missing values are nil. Parser created
+// with NewParser(format, nil) parses all the tokens into strings.
+// Returns nil map for commented line.
+func (p *Parser) ParseLineAny(line string) (map[string]any, error) {
+	if p.mapper.typ != nil {
+		return nil, ErrTypeMismatch
+	}
+
+	var values map[string]any
+	err := p.tokenize(line, func(field *field, token string) error {
+		if values == nil {
+			values = make(map[string]any, len(p.mapper.tokensSeq))
+		}
+
+		token = p.mapper.prepareToken(field, token)
+		if p.mapper.isNull(field, token) {
+			values[field.name] = nil
+			return nil
+		}
+
+		v := reflect.New(field.reflectType).Elem()
+		if err := p.mapper.processValue(field, v, token); err != nil {
+			// failed value is missing one unless parser is strict
+			values[field.name] = nil
+			return err
+		}
+		values[field.name] = v.Interface()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
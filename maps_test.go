@@ -0,0 +1,86 @@
+package hunkee
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseLineMap(t *testing.T) {
+	p, err := NewParser(`:remote_addr - [:time_local] ":request" :status :user_agent`, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	m, err := p.ParseLineMap(`10.0.0.1 - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 -`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"remote_addr": "10.0.0.1",
+		"time_local":  "10/Oct/2000:13:55:36 -0700",
+		"request":     "GET / HTTP/1.1",
+		"status":      "200",
+		"user_agent":  "-",
+	}
+	if len(m) != len(expected) {
+		t.Fatalf("unexpected map: %v", m)
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, m[k])
+		}
+	}
+
+	if m, err = p.ParseLineMap("# comment"); err != nil || m != nil {
+		t.Errorf("commented line should be skipped, got %v, %v", m, err)
+	}
+
+	var s struct{}
+	if err = p.ParseLine("10.0.0.1", &s); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("expected %s, got %v", ErrTypeMismatch, err)
+	}
+}
+
+func TestParseLineAny(t *testing.T) {
+	p, err := NewSchemaParser(":ip :date :status :bytes :rt :ok :name :dur", map[string]Kind{
+		"ip":     KindIP,
+		"date":   KindTime,
+		"status": KindInt,
+		"bytes":  KindUint,
+		"rt":     KindFloat,
+		"ok":     KindBool,
+		"dur":    KindDuration,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetTimeLayout("date", "2006-01-02")
+
+	m, err := p.ParseLineAny("10.0.0.1 2018-07-28 200 - 0.25 true Gordon 1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip, ok := m["ip"].(net.IP); !ok || !ip.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("ip: unexpected value %#v", m["ip"])
+	}
+	if d, ok := m["date"].(time.Time); !ok || d.Day() != 28 {
+		t.Errorf("date: unexpected value %#v", m["date"])
+	}
+	if m["status"] != int64(200) || m["rt"] != 0.25 || m["ok"] != true ||
+		m["name"] != "Gordon" || m["dur"] != time.Second {
+		t.Errorf("unexpected values: %v", m)
+	}
+	if v, ok := m["bytes"]; !ok || v != nil {
+		t.Errorf("bytes: missing value should be nil, got %#v", v)
+	}
+
+	if _, err = p.ParseLineAny("10.0.0.1 2018-07-28 OK - 0.25 true Gordon 1s"); err == nil {
+		t.Error("expected error of parsing status, got nil")
+	}
+
+	if _, err = NewSchemaParser(":id", map[string]Kind{"id": Kind(100)}); !errors.Is(err, ErrNotSupportedType) {
+		t.Errorf("expected %s, got %v", ErrNotSupportedType, err)
+	}
+}
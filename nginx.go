@@ -0,0 +1,183 @@
+package hunkee
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrNginxSyntax = errors.New("malformed nginx log_format directive")
+
+// nginxTimeLayouts holds layouts of nginx time variables, which are set up
+// automatically to time.Time fields with corresponded tags.
+var nginxTimeLayouts = map[string]string{
+	"time_local":   TimeLocalLayout,
+	"time_iso8601": time.RFC3339,
+}
+
+// NewParserFromNginx creates parser from nginx log_format directive, e.g.
+//
+//	log_format main '$remote_addr - $remote_user [$time_local] "$request" '
+//	                '$status $body_bytes_sent';
+//
+// Bare format string without 'log_format name' is accepted too. Variables
+// ($var or ${var}) are mapped to the fields by tag equal to variable name,
+// everything else is a literal text. Unless escape=none is set, tokens are
+// unescaped like nginx escaped them. Fields time_local and time_iso8601 get
+// corresponded time layouts.
+func NewParserFromNginx(logFormat string, to interface{}) (*Parser, error) {
+	format, escape, err := parseNginxDirective(logFormat)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := extractNginxNames(format)
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := buildMapper(tokens, to, defaultRegistry)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newParser(mapper)
+	for tag, layout := range nginxTimeLayouts {
+		// layout set up in tag takes precedence
+		if f, ok := mapper.fields[tag]; ok && f.timeOptions != nil && !f.layoutSet {
+			f.setLayout(layout)
+		}
+	}
+	p.SetUnescape(escape != "none")
+	return p, nil
+}
+
+// parseNginxDirective returns format string and escape mode of log_format
+// directive. If passed string is not a directive, it returns as is.
+func parseNginxDirective(directive string) (format, escape string, err error) {
+	directive = strings.TrimSpace(directive)
+	escape = "default"
+	if !strings.HasPrefix(directive, "log_format ") && !strings.HasPrefix(directive, "log_format\t") {
+		return directive, escape, nil
+	}
+
+	var (
+		s     = directive[len("log_format"):]
+		parts []string
+		name  string
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == ';':
+			if strings.TrimSpace(s[i+1:]) != "" {
+				return "", "", fmt.Errorf("%w: unexpected %q after ';'", ErrNginxSyntax, s[i+1:])
+			}
+			s = s[:i]
+		case c == '\'' || c == '"':
+			var b []byte
+			j := i + 1
+			for ; j < len(s) && s[j] != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+					switch s[j] {
+					case '\'', '"', '\\':
+					case 'n':
+						b = append(b, '\n')
+						continue
+					case 't':
+						b = append(b, '\t')
+						continue
+					case 'r':
+						b = append(b, '\r')
+						continue
+					default:
+						b = append(b, '\\')
+					}
+				}
+				b = append(b, s[j])
+			}
+			if j == len(s) {
+				return "", "", fmt.Errorf("%w: unterminated string at pos %d", ErrNginxSyntax, i)
+			}
+			parts = append(parts, string(b))
+			i = j
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n;'\"", rune(s[j])) {
+				j++
+			}
+			word := s[i:j]
+			switch {
+			case name == "":
+				name = word
+			case len(parts) == 0 && strings.HasPrefix(word, "escape="):
+				escape = strings.TrimPrefix(word, "escape=")
+			default:
+				parts = append(parts, word)
+			}
+			i = j - 1
+		}
+	}
+
+	if name == "" || len(parts) == 0 {
+		return "", "", fmt.Errorf("%w: name and format expected", ErrNginxSyntax)
+	}
+	if escape != "default" && escape != "json" && escape != "none" {
+		return "", "", fmt.Errorf("%w: unknown escape mode %q", ErrNginxSyntax, escape)
+	}
+	return strings.Join(parts, ""), escape, nil
+}
+
+// extractNginxNames compiles nginx format string into sequence of named
+// parameters. Each $var or ${var} becomes token, anything else is literal.
+func extractNginxNames(format string) ([]*namedParameter, error) {
+	var (
+		names   = make([]*namedParameter, 0)
+		literal []byte
+	)
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '$' || i == len(format)-1 {
+			literal = append(literal, format[i])
+			continue
+		}
+
+		var name string
+		if format[i+1] == '{' {
+			end := strings.IndexByte(format[i+2:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated variable at pos %d", ErrNginxSyntax, i)
+			}
+			name = format[i+2 : i+2+end]
+			if name == "" {
+				return nil, fmt.Errorf("%w: empty variable name at pos %d", ErrNginxSyntax, i)
+			}
+			for j := 0; j < len(name); j++ {
+				if !isNameSymbol(name[j]) {
+					return nil, fmt.Errorf("%w: unsupported symbol %q in variable %q", ErrNginxSyntax, name[j], name)
+				}
+			}
+			i += end + 2
+		} else {
+			j := i + 1
+			for j < len(format) && isNameSymbol(format[j]) {
+				j++
+			}
+			name = format[i+1 : j]
+			i = j - 1
+		}
+
+		if name == "" {
+			literal = append(literal, '$')
+			continue
+		}
+		names = append(names, &namedParameter{
+			name: name, strPos: len(names), lead: string(literal),
+		})
+		literal = literal[:0]
+	}
+
+	compileParams(names, string(literal))
+	return names, nil
+}
@@ -0,0 +1,139 @@
+package hunkee
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewParserFromNginx(t *testing.T) {
+	var s struct {
+		RemoteAddr net.IP    `hunk:"remote_addr"`
+		RemoteUser string    `hunk:"remote_user"`
+		TimeLocal  time.Time `hunk:"time_local"`
+		Request    string    `hunk:"request"`
+		Status     int       `hunk:"status"`
+		Size       uint64    `hunk:"body_bytes_sent"`
+		Referer    string    `hunk:"http_referer"`
+		UserAgent  string    `hunk:"http_user_agent"`
+	}
+
+	f := `log_format combined '$remote_addr - $remote_user [$time_local] '
+                    '"$request" $status $body_bytes_sent '
+                    '"$http_referer" "${http_user_agent}"';`
+	l := `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 612 "-" "curl/7.68.0"`
+
+	p, err := NewParserFromNginx(f, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.RemoteAddr.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("remote_addr was not parsed properly: %s", s.RemoteAddr)
+	}
+	if s.RemoteUser != "-" {
+		t.Errorf("remote_user was not parsed properly: %q != %q", s.RemoteUser, "-")
+	}
+	if _, o := s.TimeLocal.Zone(); o != -7*3600 || s.TimeLocal.Day() != 10 || s.TimeLocal.Second() != 36 {
+		t.Errorf("time_local was not parsed properly: %s", s.TimeLocal)
+	}
+	if s.Request != "GET /index.html HTTP/1.1" {
+		t.Errorf("request was not parsed properly: %q", s.Request)
+	}
+	if s.Status != 200 || s.Size != 612 {
+		t.Errorf("status or body_bytes_sent was not parsed properly: %d, %d", s.Status, s.Size)
+	}
+	if s.Referer != "-" || s.UserAgent != "curl/7.68.0" {
+		t.Errorf("quoted tokens were not parsed properly: %q, %q", s.Referer, s.UserAgent)
+	}
+}
+
+func TestNewParserFromNginxEscapeJSON(t *testing.T) {
+	var s struct {
+		Addr  string  `hunk:"remote_addr"`
+		Agent string  `hunk:"http_user_agent"`
+		Time  float64 `hunk:"request_time"`
+	}
+
+	f := `log_format js escape=json '{"addr":"$remote_addr","ua":"$http_user_agent","rt":$request_time}';`
+	p, err := NewParserFromNginx(f, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l := `{"addr":"10.0.0.1","ua":"Mozilla \"x\" é","rt":0.125}`
+	if err = p.ParseLine(l, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Addr != "10.0.0.1" {
+		t.Errorf("remote_addr was not parsed properly: %q", s.Addr)
+	}
+	if s.Agent != `Mozilla "x" é` {
+		t.Errorf("http_user_agent was not unescaped: %q", s.Agent)
+	}
+	if s.Time != 0.125 {
+		t.Errorf("request_time was not parsed properly: %f", s.Time)
+	}
+}
+
+func TestNewParserFromNginxBareFormat(t *testing.T) {
+	var s struct {
+		Host string `hunk:"host"`
+		Port int    `hunk:"server_port"`
+	}
+
+	p, err := NewParserFromNginx(`$host:$server_port`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine("example.com:8080", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Host != "example.com" || s.Port != 8080 {
+		t.Errorf("unexpected result: %q, %d", s.Host, s.Port)
+	}
+}
+
+func TestNewParserFromNginxErrors(t *testing.T) {
+	var s struct {
+		Host string `hunk:"host"`
+	}
+
+	bad := []string{
+		`log_format main '$host`,
+		`log_format main;`,
+		`log_format main escape=xml '$host';`,
+		`log_format main '${host';`,
+		`log_format main '${}';`,
+	}
+	for _, f := range bad {
+		if _, err := NewParserFromNginx(f, &s); !errors.Is(err, ErrNginxSyntax) {
+			t.Errorf("%q: expected %s, got %v", f, ErrNginxSyntax, err)
+		}
+	}
+
+	if _, err := NewParserFromNginx(`log_format main '$host $status';`, &s); err == nil {
+		t.Error("expected error about absence of field 'status', got nil")
+	}
+}
+
+func TestNewParserFromNginxTagLayout(t *testing.T) {
+	var s struct {
+		TimeLocal time.Time `hunk:"time_local,layout=2006-01-02T15:04:05"`
+		Status    int       `hunk:"status"`
+	}
+
+	p, err := NewParserFromNginx(`[$time_local] $status`, &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine(`[2000-10-10T13:55:36] 200`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.TimeLocal.Hour() != 13 || s.Status != 200 {
+		t.Errorf("layout of tag should take precedence: %+v", s)
+	}
+}
@@ -0,0 +1,98 @@
+package hunkee
+
+import "time"
+
+// TimeLocalLayout is a layout of nginx $time_local and Apache %t
+// without brackets, e.g. 10/Oct/2000:13:55:36 -0700
+const TimeLocalLayout = "02/Jan/2006:15:04:05 -0700"
+
+// Formats of widespread access logs. Time layouts of their tokens are held
+// by corresponded presets, see NewPresetParser.
+const (
+	// FormatCLF is a Common Log Format, %h %l %u %t "%r" %>s %b
+	FormatCLF = `:remote_addr - :remote_user [:time_local] ":request" :status :body_bytes_sent`
+
+	// FormatCombined is a Combined Log Format, CLF with referer and user agent
+	FormatCombined = FormatCLF + ` ":http_referer" ":http_user_agent"`
+
+	// FormatNginxMain is a 'main' log_format from default nginx.conf
+	FormatNginxMain = FormatCombined + ` ":http_x_forwarded_for"`
+)
+
+// Preset is a format of widespread access log together with time layouts
+// of its tokens by tag.
+type Preset struct {
+	Format      string
+	TimeLayouts map[string]string
+}
+
+// Presets of widespread access logs, they could be used together with
+// corresponded entry types as is:
+//
+//	var e hunkee.CombinedEntry
+//	p, err := hunkee.NewPresetParser(hunkee.PresetCombined, &e)
+var (
+	PresetCLF       = Preset{FormatCLF, map[string]string{"time_local": TimeLocalLayout}}
+	PresetCombined  = Preset{FormatCombined, map[string]string{"time_local": TimeLocalLayout}}
+	PresetNginxMain = Preset{FormatNginxMain, map[string]string{"time_local": TimeLocalLayout}}
+)
+
+// NewPresetParser creates parser of preset format for passed structure with
+// time layouts of preset set up. Options are applied after the layouts.
+func NewPresetParser(preset Preset, to interface{}, opts ...ParserOption) (*Parser, error) {
+	opts = append([]ParserOption{WithTimeLayouts(preset.TimeLayouts)}, opts...)
+	return NewParser(preset.Format, to, opts...)
+}
+
+// WithTimeLayouts sets up time layouts of time fields by tag. Unlike
+// SetMultiplyTimeLayout, layouts set up in tags are kept and tags which
+// are absent or are not of time type are ignored.
+func WithTimeLayouts(layouts map[string]string) ParserOption {
+	return func(p *Parser) error {
+		// fields are not mapped yet
+		if p.mapper == nil {
+			return nil
+		}
+		for tag, layout := range layouts {
+			if f := p.mapper.fields[tag]; f != nil && f.timeOptions != nil && !f.layoutSet {
+				f.setLayout(layout)
+			}
+		}
+		return nil
+	}
+}
+
+// CLFEntry is an entry of FormatCLF
+type CLFEntry struct {
+	RemoteAddr    string    `hunk:"remote_addr"`
+	RemoteUser    string    `hunk:"remote_user"`
+	TimeLocal     time.Time `hunk:"time_local"`
+	Request       string    `hunk:"request"`
+	Status        int       `hunk:"status"`
+	BodyBytesSent uint64    `hunk:"body_bytes_sent"`
+}
+
+// CombinedEntry is an entry of FormatCombined
+type CombinedEntry struct {
+	RemoteAddr    string    `hunk:"remote_addr"`
+	RemoteUser    string    `hunk:"remote_user"`
+	TimeLocal     time.Time `hunk:"time_local"`
+	Request       string    `hunk:"request"`
+	Status        int       `hunk:"status"`
+	BodyBytesSent uint64    `hunk:"body_bytes_sent"`
+	HTTPReferer   string    `hunk:"http_referer"`
+	HTTPUserAgent string    `hunk:"http_user_agent"`
+}
+
+// NginxMainEntry is an entry of FormatNginxMain
+type NginxMainEntry struct {
+	RemoteAddr        string    `hunk:"remote_addr"`
+	RemoteUser        string    `hunk:"remote_user"`
+	TimeLocal         time.Time `hunk:"time_local"`
+	Request           string    `hunk:"request"`
+	Status            int       `hunk:"status"`
+	BodyBytesSent     uint64    `hunk:"body_bytes_sent"`
+	HTTPReferer       string    `hunk:"http_referer"`
+	HTTPUserAgent     string    `hunk:"http_user_agent"`
+	HTTPXForwardedFor string    `hunk:"http_x_forwarded_for"`
+}
@@ -0,0 +1,145 @@
+package hunkee
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatCLF(t *testing.T) {
+	var e CLFEntry
+	p, err := NewPresetParser(PresetCLF, &e)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`
+	if err = p.ParseLine(l, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.RemoteAddr != "127.0.0.1" || e.RemoteUser != "frank" {
+		t.Errorf("unexpected remote info: %q %q", e.RemoteAddr, e.RemoteUser)
+	}
+	want := time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC)
+	if !e.TimeLocal.Equal(want) {
+		t.Errorf("time_local was not parsed properly:\nhave: %s\nwant: %s", e.TimeLocal, want)
+	}
+	if e.Request != "GET /apache_pb.gif HTTP/1.0" || e.Status != 200 || e.BodyBytesSent != 2326 {
+		t.Errorf("unexpected request info: %q %d %d", e.Request, e.Status, e.BodyBytesSent)
+	}
+}
+
+func TestFormatCombined(t *testing.T) {
+	var e CombinedEntry
+	p, err := NewPresetParser(PresetCombined, &e)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l := `192.168.1.20 - - [28/Jul/2018:21:10:45 +0000] "GET /favicon.ico HTTP/1.1" 404 0 ` +
+		`"http://example.com/" "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)"`
+	if err = p.ParseLine(l, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.RemoteUser != "-" || e.Status != 404 || e.BodyBytesSent != 0 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e.TimeLocal.Year() != 2018 || e.TimeLocal.Hour() != 21 {
+		t.Errorf("time_local was not parsed properly: %s", e.TimeLocal)
+	}
+	if e.HTTPReferer != "http://example.com/" {
+		t.Errorf("http_referer was not parsed properly: %q", e.HTTPReferer)
+	}
+	if e.HTTPUserAgent != "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)" {
+		t.Errorf("http_user_agent was not parsed properly: %q", e.HTTPUserAgent)
+	}
+}
+
+func TestFormatCombinedBareReferer(t *testing.T) {
+	var e CombinedEntry
+	p, err := NewPresetParser(PresetCombined, &e)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l := `192.168.1.20 - - [28/Jul/2018:21:10:45 +0000] "GET / HTTP/1.1" 200 0 - "curl/7.68.0"`
+	if err = p.ParseLine(l, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.BodyBytesSent != 0 || e.HTTPReferer != "-" || e.HTTPUserAgent != "curl/7.68.0" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestFormatNginxMain(t *testing.T) {
+	var e NginxMainEntry
+	p, err := NewPresetParser(PresetNginxMain, &e)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l := `10.0.0.7 - admin [04/Jan/2018:19:15:39 +0300] "POST /api/v1/items HTTP/2.0" 201 17 "-" "curl/7.58.0" "203.0.113.9, 10.0.0.1"`
+	if err = p.ParseLine(l, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.RemoteAddr != "10.0.0.7" || e.RemoteUser != "admin" || e.Status != 201 || e.BodyBytesSent != 17 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if _, o := e.TimeLocal.Zone(); o != 3*3600 || e.TimeLocal.Day() != 4 {
+		t.Errorf("time_local was not parsed properly: %s", e.TimeLocal)
+	}
+	if e.HTTPReferer != "-" || e.HTTPUserAgent != "curl/7.58.0" {
+		t.Errorf("unexpected referer or user agent: %q %q", e.HTTPReferer, e.HTTPUserAgent)
+	}
+	if e.HTTPXForwardedFor != "203.0.113.9, 10.0.0.1" {
+		t.Errorf("http_x_forwarded_for was not parsed properly: %q", e.HTTPXForwardedFor)
+	}
+}
+
+func TestFormatCLFTagLayout(t *testing.T) {
+	var e struct {
+		RemoteAddr    string    `hunk:"remote_addr"`
+		RemoteUser    string    `hunk:"remote_user"`
+		TimeLocal     time.Time `hunk:"time_local,layout=2006-01-02T15:04:05"`
+		Request       string    `hunk:"request"`
+		Status        int       `hunk:"status"`
+		BodyBytesSent uint64    `hunk:"body_bytes_sent"`
+	}
+	p, err := NewPresetParser(PresetCLF, &e)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l := `127.0.0.1 - frank [2000-10-10T13:55:36] "GET /apache_pb.gif HTTP/1.0" 200 2326`
+	if err = p.ParseLine(l, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.TimeLocal.Hour() != 13 || e.TimeLocal.Minute() != 55 {
+		t.Errorf("layout of tag should take precedence: %s", e.TimeLocal)
+	}
+}
+
+func TestWithTimeLayouts(t *testing.T) {
+	var e CLFEntry
+	// equivalent of FormatCLF is not a preset, so its layouts are set up explicitly
+	f := ":remote_addr  -  :remote_user [:time_local] \":request\" :status :body_bytes_sent"
+	p, err := NewParser(f, &e, WithTimeLayouts(map[string]string{"time_local": TimeLocalLayout, "missing": time.Kitchen}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l := `127.0.0.1  -  frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`
+	if err = p.ParseLine(l, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.TimeLocal.Day() != 10 || e.TimeLocal.Hour() != 13 {
+		t.Errorf("time_local was not parsed properly: %s", e.TimeLocal)
+	}
+
+	// no layouts are guessed by format string
+	if p, err = NewParser(FormatCLF, &e); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if issues := p.Validate(); len(issues) != 1 || issues[0].Kind != IssueNoTimeLayout {
+		t.Errorf("expected issue of time_local layout, got %v", issues)
+	}
+}
@@ -1,40 +1,140 @@
 package hunkee
 
 import (
+	"encoding"
 	"fmt"
 	"net"
+	"net/netip"
 	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // processField gets token and parse it into corresponded type and puts into 'final' value
 func (m *mapper) processField(field *field, final reflect.Value, token string) error {
-	v := final.FieldByIndex(field.index)
-	if v.Kind() == reflect.Ptr && v.IsNil() {
-		// Allocate memory
-		v.Set(reflect.New(deref(v.Type())))
+	// set raw value, raw fields of other types are reported by Validate
+	if field.hasRaw {
+		if raw := m.raw(field); raw != nil && raw.reflectType.Kind() == reflect.String {
+			fieldByIndex(final, raw.index).SetString(token)
+		}
+	}
+	// token is mapped to raw field only
+	if field.index == nil {
+		return nil
 	}
 
-	// set raw value
-	if field.hasRaw {
-		raw := m.raw(field)
-		if raw == nil {
-			panic(fmt.Sprintf("%s field should have raw field, but it's not provided", field.name))
+	return m.processValue(field, fieldByIndex(final, field.index), m.prepareToken(field, token))
+}
+
+// prepareToken unescapes token and replaces missing value with default one
+func (m *mapper) prepareToken(field *field, token string) string {
+	// raw field keeps escaped form of token
+	if m.unescape {
+		token = unescapeToken(token)
+	}
+
+	if field.hasDefault && (token == "" || m.isNull(field, token)) {
+		token = field.def
+	}
+	return token
+}
+
+// fieldByIndex returns nested field of v by index allocating nil
+// pointers to embedded or nested structs on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	if len(index) == 1 {
+		return v.Field(index[0])
+	}
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
 		}
-		final.Field(raw.index[0]).Set(reflect.ValueOf(token))
+		v = v.Field(x)
 	}
+	return v
+}
 
-	// nothing to process, but if it's string, we should set token to the field
-	if token == "-" {
-		if field.reflectKind == reflect.String {
+// existingField works like fieldByIndex, but reports false instead of
+// allocating nil pointer on the way.
+func existingField(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// processValue parses token into v, which is either a field of structure
+// or element of slice field.
+func (m *mapper) processValue(field *field, v reflect.Value, token string) error {
+	// nothing to process: pointer stays nil, sql.Null* becomes invalid,
+	// string gets null marker as is, anything else gets zero value
+	if m.isNull(field, token) || (field.pointer || field.omitEmpty) && token == "" {
+		switch {
+		case field.pointer:
+			v.Set(reflect.Zero(v.Type()))
+		case field.reflectKind == reflect.String && !field.custom() && !field.sqlNull:
 			v.SetString(token)
+		default:
+			v.Set(reflect.Zero(v.Type()))
 		}
 		return nil
 	}
 
+	if field.pointer {
+		if v.IsNil() {
+			// Allocate memory
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	// sql.Null* is valid only if its value is parsed
+	if field.sqlNull {
+		valid := v.Field(1)
+		valid.SetBool(false)
+		if err := m.parseValue(field, v.Field(0), token); err != nil {
+			return err
+		}
+		valid.SetBool(true)
+		return nil
+	}
+
+	return m.parseValue(field, v, token)
+}
+
+// parseValue parses token into v, which holds value of field
+// (pointers are dereferenced, sql.Null* are unwrapped)
+func (m *mapper) parseValue(field *field, v reflect.Value, token string) error {
+	if field.ftype == typeRegistered {
+		return field.parse(token, v)
+	}
+
+	// custom types parse token themselves
+	if field.custom() {
+		return unmarshalToken(v, token, field)
+	}
+
+	// empty token is zero value, like for plain numbers
+	switch field.ftype {
+	case typeDuration, typeAddr, typeAddrPort, typePrefix:
+		if token == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+	}
+
 	switch field.reflectKind {
 	case reflect.Bool:
 		b, err := strconv.ParseBool(token)
@@ -43,15 +143,18 @@ func (m *mapper) processField(field *field, final reflect.Value, token string) e
 		}
 		v.SetBool(b)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.ftype == typeDuration {
+			return parseStringToStruct(v, token, field)
+		}
 		i64, err := parseInt(field.reflectKind, token)
 		if err != nil && token != "" {
-			return fmt.Errorf("field: %s parse: %s", field.name, err)
+			return err
 		}
 		v.SetInt(i64)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		ui64, err := parseUint(field.reflectKind, token)
 		if err != nil && token != "" {
-			return fmt.Errorf("field: %s parse: %s", field.name, err)
+			return err
 		}
 		v.SetUint(ui64)
 	case reflect.String:
@@ -59,25 +162,142 @@ func (m *mapper) processField(field *field, final reflect.Value, token string) e
 	case reflect.Float32, reflect.Float64:
 		fl64, err := parseFloat(field.reflectKind, token)
 		if err != nil && token != "" {
-			return fmt.Errorf("field: %s parse: %s", field.name, err)
+			return err
 		}
 		v.SetFloat(fl64)
 	case reflect.Struct:
-		if err := parseStringToStruct(v, token, field); err != nil {
-			return fmt.Errorf("field: %s parse: %s", field.name, err)
+		return parseStringToStruct(v, token, field)
+	case reflect.Slice:
+		if field.elem != nil {
+			return m.processList(field, v, token)
 		}
+		fallthrough
 	default:
 		if field.ftype == typeIP {
 			ip := net.ParseIP(token)
+			if ip == nil && token != "" {
+				return fmt.Errorf("invalid IP address %q", token)
+			}
 			v.Set(reflect.ValueOf(ip))
 		} else {
-			return fmt.Errorf("type %+v is not supported", field)
+			return fmt.Errorf("%w: %s", ErrNotSupportedType, field.reflectType)
 		}
 	}
 
 	return nil
 }
 
+// processList splits token by list separators of field and parses each
+// element into slice v. Capacity of slice is reused if it's enough.
+func (m *mapper) processList(field *field, v reflect.Value, token string) error {
+	if token == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	n := 0
+	for rest, ok := token, true; ok; n++ {
+		_, rest, ok = nextListItem(rest, field.listSeps)
+	}
+
+	list := v
+	if v.Cap() >= n {
+		list = v.Slice(0, n)
+	} else {
+		list = reflect.MakeSlice(v.Type(), n, n)
+	}
+
+	item, rest, ok := "", token, true
+	for i := 0; ok; i++ {
+		item, rest, ok = nextListItem(rest, field.listSeps)
+		if err := m.processValue(field.elem, list.Index(i), strings.TrimSpace(item)); err != nil {
+			return err
+		}
+	}
+	v.Set(list)
+	return nil
+}
+
+// nextListItem returns token part before the first of separators and the
+// rest after it. ok is false if there is no separator in token.
+func nextListItem(token string, seps []string) (item, rest string, ok bool) {
+	end, size := -1, 0
+	for i := 0; i < len(seps); i++ {
+		if j := strings.Index(token, seps[i]); j >= 0 && (end < 0 || j < end) {
+			end, size = j, len(seps[i])
+		}
+	}
+	if end < 0 {
+		return token, "", false
+	}
+	return token[:end], token[end+size:], true
+}
+
+// unescapeToken replaces backslash escape sequences like \", \x22 and \u0022
+// with symbols they stand for. Unknown sequences are left as is.
+func unescapeToken(token string) string {
+	i := strings.IndexByte(token, '\\')
+	if i < 0 {
+		return token
+	}
+
+	b := make([]byte, 0, len(token))
+	b = append(b, token[:i]...)
+	for ; i < len(token); i++ {
+		if token[i] != '\\' || i == len(token)-1 {
+			b = append(b, token[i])
+			continue
+		}
+
+		switch c := token[i+1]; c {
+		case '"', '\'', '\\', '/':
+			b = append(b, c)
+			i++
+		case 'n':
+			b = append(b, '\n')
+			i++
+		case 't':
+			b = append(b, '\t')
+			i++
+		case 'r':
+			b = append(b, '\r')
+			i++
+		case 'x':
+			if i+3 < len(token) {
+				if v, err := strconv.ParseUint(token[i+2:i+4], 16, 8); err == nil {
+					b = append(b, byte(v))
+					i += 3
+					continue
+				}
+			}
+			b = append(b, token[i])
+		case 'u':
+			if i+5 < len(token) {
+				if v, err := strconv.ParseUint(token[i+2:i+6], 16, 16); err == nil {
+					b = utf8.AppendRune(b, rune(v))
+					i += 5
+					continue
+				}
+			}
+			b = append(b, token[i])
+		default:
+			b = append(b, token[i])
+		}
+	}
+	return string(b)
+}
+
+// unmarshalToken passes token to UnmarshalHunk or UnmarshalText method of value
+func unmarshalToken(v reflect.Value, token string, field *field) error {
+	if v.Kind() != reflect.Ptr {
+		v = v.Addr()
+	}
+	if field.ftype == typeUnmarshaler {
+		return v.Interface().(Unmarshaler).UnmarshalHunk(token)
+	}
+	return v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(token))
+}
+
 func parseUint(kind reflect.Kind, token string) (uint64, error) {
 	var size int
 	switch kind {
@@ -129,7 +349,9 @@ func parseFloat(kind reflect.Kind, token string) (float64, error) {
 }
 
 // parseStringToStruct gets token and parses it into
-// net.Addr, time.Time, time.Duration, url.URL
+// time.Time, time.Duration, url.URL, netip.Addr, netip.AddrPort, netip.Prefix.
+// time.Duration could be written as "1.5s" or as plain number of
+// durationUnit (nanoseconds by default).
 func parseStringToStruct(v reflect.Value, token string, field *field) (err error) {
 	switch field.ftype {
 	case typeTime:
@@ -148,26 +370,69 @@ func parseStringToStruct(v reflect.Value, token string, field *field) (err error
 		if err != nil {
 			return err
 		}
+	case typeAddr:
+		// write via pointer to avoid allocation, like for time.Time
+		*v.Addr().Interface().(*netip.Addr), err = netip.ParseAddr(token)
+		if err != nil {
+			return err
+		}
+	case typeAddrPort:
+		*v.Addr().Interface().(*netip.AddrPort), err = netip.ParseAddrPort(token)
+		if err != nil {
+			return err
+		}
+	case typePrefix:
+		*v.Addr().Interface().(*netip.Prefix), err = netip.ParsePrefix(token)
+		if err != nil {
+			return err
+		}
 	case typeURL:
 		u, err := url.Parse(token)
 		if err != nil {
 			return err
 		}
-		v.Set(reflect.ValueOf(u))
+		if v.Kind() == reflect.Ptr {
+			v.Set(reflect.ValueOf(u))
+		} else {
+			v.Set(reflect.ValueOf(*u))
+		}
 	case typeDuration:
-		d, err := time.ParseDuration(token)
-		if err != nil {
-			return err
+		var d time.Duration
+		if field.durationUnit != 0 {
+			// plain number of units, like 1234 microseconds or 0.125 seconds
+			f, err := strconv.ParseFloat(token, 64)
+			if err != nil {
+				return err
+			}
+			d = time.Duration(f * float64(field.durationUnit))
+		} else if d, err = time.ParseDuration(token); err != nil {
+			// plain number of nanoseconds
+			n, nerr := strconv.ParseInt(token, 10, 64)
+			if nerr != nil {
+				return err
+			}
+			d = time.Duration(n)
 		}
 		v.Set(reflect.ValueOf(d))
 	default:
-		return fmt.Errorf("type %s is not supported: ftype: %d name: %s", field.reflectType, field.ftype, field.name)
+		return fmt.Errorf("%w: %s", ErrNotSupportedType, field.reflectType)
 	}
 	return nil
 }
 
-// processTag returns full tag, normalName aka not raw name and error, if exists
-func processTag(tagLine reflect.StructTag) (tag, normalName string, err error) {
+// tagOptions holds field options set up in struct tag after the name:
+// hunk:"time_local,layout=02/Jan/2006:15:04:05 -0700,tz=UTC"
+type tagOptions struct {
+	layout    string         // time layout
+	location  *time.Location // time location
+	def       *string        // token used instead of null or empty one
+	seps      []string       // list separators
+	prefix    *string        // tag prefix of nested struct fields
+	omitEmpty bool           // empty token is a missing value
+}
+
+// processTag returns full tag, normalName aka not raw name, tag options and error, if exists
+func processTag(tagLine reflect.StructTag) (tag, normalName string, opts *tagOptions, err error) {
 	var ok bool
 	tag, ok = tagLine.Lookup(libtag)
 	if !ok || tag == "" || tag == unexportedTag {
@@ -175,9 +440,15 @@ func processTag(tagLine reflect.StructTag) (tag, normalName string, err error) {
 		return
 	}
 
-	if strings.ContainsAny(tag, ".,") {
-		err = ErrComaNotSupported
-		return
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		if opts, err = parseTagOptions(tag[i+1:]); err != nil {
+			return
+		}
+		tag = tag[:i]
+		if tag == "" {
+			// only options are set, e.g. prefix of nested struct
+			tag = unexportedTag
+		}
 	}
 
 	if strings.HasSuffix(tag, "_raw") {
@@ -186,3 +457,74 @@ func processTag(tagLine reflect.StructTag) (tag, normalName string, err error) {
 
 	return
 }
+
+// parseTagOptions parses comma-separated options of tag. Since time layouts
+// could contain commas, part without '=' which is not a known flag is
+// considered to be a continuation of previous option value.
+func parseTagOptions(line string) (*tagOptions, error) {
+	var (
+		opts  = new(tagOptions)
+		parts []string
+	)
+	for _, part := range strings.Split(line, ",") {
+		if !strings.Contains(part, "=") && part != "omitempty" && len(parts) > 0 {
+			parts[len(parts)-1] += "," + part
+			continue
+		}
+		parts = append(parts, part)
+	}
+
+	for _, part := range parts {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "layout":
+			opts.layout = value
+		case "tz":
+			loc, err := time.LoadLocation(value)
+			if err != nil {
+				return nil, fmt.Errorf("tag option %q: %s", part, err)
+			}
+			opts.location = loc
+		case "default":
+			opts.def = &value
+		case "sep":
+			if value == "" {
+				return nil, fmt.Errorf("%w: empty separator", ErrUnknownOption)
+			}
+			opts.seps = append(opts.seps, value)
+		case "prefix":
+			opts.prefix = &value
+		case "omitempty":
+			opts.omitEmpty = true
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrUnknownOption, part)
+		}
+	}
+	return opts, nil
+}
+
+// apply sets up options to the field
+func (opts *tagOptions) apply(f *field) error {
+	if opts.layout != "" || opts.location != nil {
+		if f.timeOptions == nil {
+			return fmt.Errorf("%w: layout and tz options are applicable to time fields only", ErrUnknownOption)
+		}
+		if opts.layout != "" {
+			f.setLayout(opts.layout)
+		}
+		if opts.location != nil {
+			f.timeOptions.Location = opts.location
+		}
+	}
+	if opts.seps != nil {
+		if f.elem == nil {
+			return fmt.Errorf("%w: sep option is applicable to slice fields only", ErrUnknownOption)
+		}
+		f.listSeps = opts.seps
+	}
+	if opts.def != nil {
+		f.def, f.hasDefault = *opts.def, true
+	}
+	f.omitEmpty = opts.omitEmpty
+	return nil
+}
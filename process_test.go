@@ -1,11 +1,14 @@
 package hunkee
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -180,6 +183,25 @@ func TestParseFloat(t *testing.T) {
 	}
 }
 
+func TestUnescapeToken(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		`plain`:           `plain`,
+		`say \"hi\"`:      `say "hi"`,
+		`\x22quoted\x22`:  `"quoted"`,
+		`back\\slash`:     `back\slash`,
+		`tab\there`:       "tab\there",
+		`bad \xZZ and \q`: `bad \xZZ and \q`,
+		`trailing \`:      `trailing \`,
+	}
+	for in, want := range cases {
+		if have := unescapeToken(in); have != want {
+			t.Errorf("unescape %q:\nhave: %q\nwant: %q", in, have, want)
+		}
+	}
+}
+
 func TestParseStringToStructTime(t *testing.T) {
 	t.Parallel()
 
@@ -290,7 +312,7 @@ func TestProcessFieldInt(t *testing.T) {
 	}
 	s := new(st)
 
-	m, err := initMapper(":ui :i :b :s", s)
+	m, err := initMapper(":ui :i :b :s", s, defaultRegistry)
 	if err != nil {
 		t.Error(err)
 	}
@@ -331,7 +353,7 @@ func TestProcessFieldUint(t *testing.T) {
 	s := new(st)
 	format := ":ui :i :b :s"
 
-	m, err := initMapper(format, s)
+	m, err := initMapper(format, s, defaultRegistry)
 	if err != nil {
 		t.Error(err)
 	}
@@ -367,7 +389,7 @@ func TestProcessFieldBool(t *testing.T) {
 	}
 	s := new(st)
 
-	m, err := initMapper(":b ", s)
+	m, err := initMapper(":b ", s, defaultRegistry)
 	if err != nil {
 		t.Error(err)
 	}
@@ -398,7 +420,7 @@ func TestProcessFieldFloat(t *testing.T) {
 	}
 	s := new(st)
 
-	m, err := initMapper(":f", s)
+	m, err := initMapper(":f", s, defaultRegistry)
 	if err != nil {
 		t.Error(err)
 	}
@@ -426,7 +448,7 @@ func TestProcessFieldString(t *testing.T) {
 	}
 	s := new(st)
 
-	m, err := initMapper(":s", s)
+	m, err := initMapper(":s", s, defaultRegistry)
 	if err != nil {
 		t.Error(err)
 	}
@@ -449,7 +471,7 @@ func TestProcessFieldNotSupported(t *testing.T) {
 	}
 	s := new(st)
 
-	m, err := initMapper(":s", s)
+	m, err := initMapper(":s", s, defaultRegistry)
 	if err != nil {
 		t.Error(err)
 	}
@@ -461,6 +483,81 @@ func TestProcessFieldNotSupported(t *testing.T) {
 	}
 }
 
+type cacheStatus int
+
+const (
+	cacheMiss cacheStatus = iota + 1
+	cacheHit
+)
+
+func (c *cacheStatus) UnmarshalHunk(token string) error {
+	switch token {
+	case "HIT":
+		*c = cacheHit
+	case "MISS":
+		*c = cacheMiss
+	default:
+		return fmt.Errorf("unknown cache status %q", token)
+	}
+	return nil
+}
+
+type requestID struct {
+	hi, lo string
+}
+
+func (r *requestID) UnmarshalText(text []byte) error {
+	parts := strings.SplitN(string(text), "-", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("malformed request id %q", text)
+	}
+	r.hi, r.lo = parts[0], parts[1]
+	return nil
+}
+
+func TestProcessFieldUnmarshaler(t *testing.T) {
+	t.Parallel()
+
+	type st struct {
+		Cache    cacheStatus `hunk:"cache"`
+		ID       requestID   `hunk:"id"`
+		ParentID *requestID  `hunk:"parent_id"`
+	}
+	s := new(st)
+
+	m, err := initMapper(":cache :id :parent_id", s, defaultRegistry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest := reflect.Indirect(reflect.ValueOf(s))
+	if err = m.processField(m.getField("cache"), dest, "HIT"); err != nil {
+		t.Error(err)
+	}
+	if err = m.processField(m.getField("id"), dest, "abc-123"); err != nil {
+		t.Error(err)
+	}
+	if err = m.processField(m.getField("parent_id"), dest, "def-456"); err != nil {
+		t.Error(err)
+	}
+	if s.Cache != cacheHit {
+		t.Errorf("UnmarshalHunk was not called, got %d", s.Cache)
+	}
+	if s.ID.hi != "abc" || s.ID.lo != "123" {
+		t.Errorf("UnmarshalText was not called, got %+v", s.ID)
+	}
+	if s.ParentID == nil || s.ParentID.hi != "def" {
+		t.Errorf("UnmarshalText was not called on pointer field, got %+v", s.ParentID)
+	}
+
+	if err = m.processField(m.getField("cache"), dest, "STALE"); err == nil {
+		t.Error("expected error of unknown cache status, got nil")
+	}
+	if err = m.processField(m.getField("id"), dest, "abc"); err == nil {
+		t.Error("expected error of malformed request id, got nil")
+	}
+}
+
 func TestProcessFieldIP(t *testing.T) {
 	t.Parallel()
 
@@ -470,7 +567,7 @@ func TestProcessFieldIP(t *testing.T) {
 	}
 	s := new(st)
 
-	m, err := initMapper(":ip", s)
+	m, err := initMapper(":ip", s, defaultRegistry)
 	if err != nil {
 		t.Error(err)
 	}
@@ -488,22 +585,103 @@ func TestProcessFieldIP(t *testing.T) {
 	}
 }
 
+func TestProcessFieldInvalidIP(t *testing.T) {
+	t.Parallel()
+
+	type st struct {
+		IP net.IP `hunk:"ip"`
+	}
+	s := new(st)
+
+	m, err := initMapper(":ip", s, defaultRegistry)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = m.processField(m.getField("ip"), reflect.Indirect(reflect.ValueOf(s)), "300.1.1.1")
+	if err == nil {
+		t.Error("expected error of invalid IP address, got nil")
+	}
+}
+
+func TestProcessFieldNetip(t *testing.T) {
+	t.Parallel()
+
+	type st struct {
+		Addr     netip.Addr     `hunk:"addr"`
+		Upstream netip.AddrPort `hunk:"upstream"`
+		Net      netip.Prefix   `hunk:"net"`
+	}
+	s := new(st)
+
+	m, err := initMapper(":addr :upstream :net", s, defaultRegistry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest := reflect.Indirect(reflect.ValueOf(s))
+	tokens := map[string]string{
+		"addr":     "2001:db8::1",
+		"upstream": "10.0.0.1:8080",
+		"net":      "192.168.0.0/16",
+	}
+	for tag, token := range tokens {
+		if err = m.processField(m.getField(tag), dest, token); err != nil {
+			t.Error(err)
+		}
+	}
+	if s.Addr != netip.MustParseAddr("2001:db8::1") {
+		t.Errorf("netip.Addr was parsed wrong, expect %q, got %q", tokens["addr"], s.Addr)
+	}
+	if s.Upstream.Addr() != netip.MustParseAddr("10.0.0.1") || s.Upstream.Port() != 8080 {
+		t.Errorf("netip.AddrPort was parsed wrong, expect %q, got %q", tokens["upstream"], s.Upstream)
+	}
+	if s.Net.Bits() != 16 || !s.Net.Contains(netip.MustParseAddr("192.168.10.1")) {
+		t.Errorf("netip.Prefix was parsed wrong, expect %q, got %q", tokens["net"], s.Net)
+	}
+
+	invalid := map[string]string{
+		"addr":     "10.0.0.256",
+		"upstream": "10.0.0.1",
+		"net":      "192.168.0.0/33",
+	}
+	for tag, token := range invalid {
+		if err = m.processField(m.getField(tag), dest, token); err == nil {
+			t.Errorf("expected error for %q into %s, got nil", token, tag)
+		}
+	}
+}
+
 func TestProcessTag(t *testing.T) {
 	tag := reflect.StructTag("")
-	_, _, err := processTag(tag)
+	_, _, _, err := processTag(tag)
 	if err != nil {
 		t.Error(err)
 	}
 
 	tag = reflect.StructTag(`hunk:""`)
-	_, _, err = processTag(tag)
+	_, _, _, err = processTag(tag)
 	if err != nil {
 		t.Error(err)
 	}
 
 	tag = reflect.StructTag(`hunk:"alia,s"`)
-	_, _, err = processTag(tag)
-	if err != ErrComaNotSupported {
-		t.Errorf("expected %s error, got %v", ErrComaNotSupported, err)
+	_, _, _, err = processTag(tag)
+	if !errors.Is(err, ErrUnknownOption) {
+		t.Errorf("expected %s error, got %v", ErrUnknownOption, err)
+	}
+
+	tag = reflect.StructTag(`hunk:"date,layout=Mon, 02 Jan 2006 15:04:05 MST,tz=UTC,omitempty"`)
+	name, _, opts, err := processTag(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "date" || opts.layout != time.RFC1123 || opts.location != time.UTC || !opts.omitEmpty {
+		t.Errorf("unexpected tag options of %q: %+v", tag, opts)
+	}
+
+	tag = reflect.StructTag(`hunk:"date,tz=Mars/Olympus"`)
+	if _, _, _, err = processTag(tag); err == nil {
+		t.Error("expected error of unknown location, got nil")
 	}
 }
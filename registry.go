@@ -0,0 +1,108 @@
+package hunkee
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ParseFunc parses token into dst, which is settable value of registered type
+type ParseFunc func(token string, dst reflect.Value) error
+
+// registry holds parse functions of registered types. Pointer types are
+// registered by type they point to. Types absent in registry are looked
+// up in its parent.
+type registry struct {
+	mu     sync.RWMutex
+	funcs  map[reflect.Type]ParseFunc
+	parent *registry
+}
+
+var defaultRegistry = newRegistry(nil)
+
+func newRegistry(parent *registry) *registry {
+	return &registry{funcs: make(map[reflect.Type]ParseFunc), parent: parent}
+}
+
+func (r *registry) register(t reflect.Type, fn ParseFunc) {
+	if t == nil || fn == nil {
+		panic("passed nil type or parse function")
+	}
+	r.mu.Lock()
+	r.funcs[deref(t)] = fn
+	r.mu.Unlock()
+}
+
+func (r *registry) lookup(t reflect.Type) ParseFunc {
+	r.mu.RLock()
+	fn := r.funcs[deref(t)]
+	r.mu.RUnlock()
+	if fn == nil && r.parent != nil {
+		return r.parent.lookup(t)
+	}
+	return fn
+}
+
+// RegisterType teaches all parsers created after the call to parse fields
+// of type t (or pointers to t) with fn. Registered types take precedence
+// over built-in ones, so it could be used for third-party types like
+// uuid.UUID or decimal.Decimal:
+//
+//	hunkee.RegisterType(reflect.TypeOf(uuid.UUID{}), func(token string, dst reflect.Value) error {
+//		id, err := uuid.Parse(token)
+//		if err != nil {
+//			return err
+//		}
+//		dst.Set(reflect.ValueOf(id))
+//		return nil
+//	})
+func RegisterType(t reflect.Type, fn ParseFunc) {
+	defaultRegistry.register(t, fn)
+}
+
+// WithType teaches parser to parse fields of type t (or pointers to t) with
+// fn. Unlike Parser.RegisterType, it's applied before fields are mapped, so
+// struct types are parsed by fn instead of being walked as nested structs.
+// It takes precedence over package-level registered types.
+func WithType(t reflect.Type, fn ParseFunc) ParserOption {
+	if t == nil || fn == nil {
+		panic("passed nil type or parse function")
+	}
+	return func(p *Parser) error {
+		if p.mapper == nil {
+			p.types.register(t, fn)
+		}
+		return nil
+	}
+}
+
+// RegisterType teaches parser to parse fields of type t (or pointers to t)
+// with fn. It takes precedence over package-level registered types. Fields
+// of struct types are mapped as nested structs already, use WithType for them.
+func (p *Parser) RegisterType(t reflect.Type, fn ParseFunc) {
+	if t == nil || fn == nil {
+		panic("passed nil type or parse function")
+	}
+	for _, f := range p.mapper.fields {
+		// elements of slice fields could be of registered type too
+		for ; f != nil; f = f.elem {
+			f.registerType(t, fn)
+		}
+	}
+}
+
+// registerType makes field parsed by fn if it holds value of type t
+func (f *field) registerType(t reflect.Type, fn ParseFunc) {
+	if f.reflectType == nil {
+		return
+	}
+	switch elem := deref(f.reflectType); {
+	case elem == deref(t):
+		f.ftype = typeRegistered
+		f.parse = fn
+		f.sqlNull = false
+		f.reflectKind = elem.Kind()
+	case f.sqlNull && elem.Field(0).Type == deref(t):
+		f.ftype = typeRegistered
+		f.parse = fn
+	}
+}
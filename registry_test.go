@@ -0,0 +1,121 @@
+package hunkee
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type celsius float64
+
+type hexID uint32
+
+func TestRegisterType(t *testing.T) {
+	// package-level registry is shared by other tests
+	t.Cleanup(func() {
+		defaultRegistry.mu.Lock()
+		delete(defaultRegistry.funcs, reflect.TypeOf(hexID(0)))
+		defaultRegistry.mu.Unlock()
+	})
+	RegisterType(reflect.TypeOf(hexID(0)), func(token string, dst reflect.Value) error {
+		u, err := strconv.ParseUint(strings.TrimPrefix(token, "0x"), 16, 32)
+		if err != nil {
+			return err
+		}
+		dst.SetUint(u)
+		return nil
+	})
+
+	var s struct {
+		ID     hexID    `hunk:"id"`
+		Parent *hexID   `hunk:"parent"`
+		Temp   celsius  `hunk:"temp"`
+		Plain  float64  `hunk:"plain"`
+		Other  *celsius `hunk:"other"`
+	}
+
+	p, err := NewParser(":id :parent :temp :plain :other", &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.RegisterType(reflect.TypeOf(celsius(0)), func(token string, dst reflect.Value) error {
+		if !strings.HasSuffix(token, "C") {
+			return fmt.Errorf("%q is not in celsius", token)
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(token, "C"), 64)
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(f)
+		return nil
+	})
+
+	if err = p.ParseLine("0xff 0x10 25.5C 1.5 -3C", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 255 || s.Parent == nil || *s.Parent != 16 {
+		t.Errorf("package-level registered type was not parsed properly: %d, %v", s.ID, s.Parent)
+	}
+	if s.Temp != 25.5 || s.Other == nil || *s.Other != -3 {
+		t.Errorf("parser registered type was not parsed properly: %f, %v", s.Temp, s.Other)
+	}
+	if s.Plain != 1.5 {
+		t.Errorf("built-in type should not be affected: %f", s.Plain)
+	}
+
+	if err = p.ParseLine("0xff 0x10 25.5F 1.5 -3C", &s); err == nil {
+		t.Error("expected error of registered parse function, got nil")
+	}
+}
+
+type money struct {
+	Units, Nanos int64
+}
+
+func TestWithType(t *testing.T) {
+	parseMoney := func(token string, dst reflect.Value) error {
+		units, nanos, _ := strings.Cut(token, ".")
+		u, err := strconv.ParseInt(units, 10, 64)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(money{u, n}))
+		return nil
+	}
+
+	var s struct {
+		Price money  `hunk:"m"`
+		Tax   *money `hunk:"tax"`
+		Name  string `hunk:"name"`
+	}
+
+	// struct type would be walked as nested struct otherwise
+	if _, err := NewParser(":m :tax :name", &s); err == nil {
+		t.Fatal("expected error of missing tag, got nil")
+	}
+
+	p, err := NewParser(":m :tax :name", &s, WithType(reflect.TypeOf(money{}), parseMoney), WithValidation())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = p.ParseLine("12.5 1.25 apple", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Price != (money{12, 5}) || s.Tax == nil || *s.Tax != (money{1, 25}) || s.Name != "apple" {
+		t.Errorf("registered struct type was not parsed properly: %+v", s)
+	}
+	if err = p.ParseLine("12 1.25 apple", &s); err == nil {
+		t.Error("expected error of registered parse function, got nil")
+	}
+
+	// package-level registry is not affected
+	if defaultRegistry.lookup(reflect.TypeOf(money{})) != nil {
+		t.Error("type registered by option leaked into package-level registry")
+	}
+}
@@ -0,0 +1,104 @@
+package hunkee
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Scanner reads log entries line by line from io.Reader. Commented and
+// empty lines are skipped, lines could be of any length and could end
+// with either \n or \r\n.
+//
+//	s := p.NewScanner(f)
+//	for s.Scan() {
+//		if err := s.Decode(&entry); err != nil {
+//			log.Printf("line %d: %s", s.LineNumber(), err)
+//		}
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+type Scanner struct {
+	parser *Parser
+	reader *bufio.Reader
+	buf    []byte
+	line   string
+	lineNo int
+	err    error
+}
+
+// NewScanner returns Scanner which reads lines from r and parses them with p.
+func (p *Parser) NewScanner(r io.Reader) *Scanner {
+	return &Scanner{
+		parser: p,
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Scan advances Scanner to the next line which should be decoded.
+// It returns false when the end of input is reached or an error occurred.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	m := s.parser.mapper
+	for {
+		line, err := s.readLine()
+		if err != nil && (err != io.EOF || line == "") {
+			if err != io.EOF {
+				s.err = err
+			}
+			s.line = ""
+			return false
+		}
+		s.lineNo++
+
+		if line == "" || m.prefixActive && strings.HasPrefix(line, m.comPrefix) {
+			if err == io.EOF {
+				s.line = ""
+				return false
+			}
+			continue
+		}
+		s.line = line
+		return true
+	}
+}
+
+// readLine reads whole line without trailing \n or \r\n
+func (s *Scanner) readLine() (string, error) {
+	s.buf = s.buf[:0]
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		s.buf = append(s.buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		line := strings.TrimRight(string(s.buf), "\r\n")
+		return line, err
+	}
+}
+
+// Decode parses current line into dest.
+func (s *Scanner) Decode(dest interface{}) error {
+	return s.parser.ParseLine(s.line, dest)
+}
+
+// Err returns the first non-EOF error occurred during reading.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Line returns current line without line ending.
+func (s *Scanner) Line() string {
+	return s.line
+}
+
+// LineNumber returns number of current line, starting from 1.
+// Skipped lines are counted too.
+func (s *Scanner) LineNumber() int {
+	return s.lineNo
+}
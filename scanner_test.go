@@ -0,0 +1,79 @@
+package hunkee
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestScanner(t *testing.T) {
+	type entry struct {
+		ID   int    `hunk:"id"`
+		Name string `hunk:"name"`
+	}
+
+	p, err := NewParser(":id :name", &entry{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	long := strings.Repeat("x", 100*1024)
+	input := "# header\r\n1 alpha\r\n\n2 " + long + "\n#3 commented\n4 delta"
+
+	var (
+		s     = p.NewScanner(strings.NewReader(input))
+		ids   []int
+		lines []int
+	)
+	for s.Scan() {
+		var e entry
+		if err := s.Decode(&e); err != nil {
+			t.Fatalf("line %d: %s", s.LineNumber(), err)
+		}
+		if e.ID == 2 && e.Name != long {
+			t.Errorf("long line was not read properly: %d bytes instead of %d", len(e.Name), len(long))
+		}
+		if e.ID == 1 && s.Line() != "1 alpha" {
+			t.Errorf("line ending was not trimmed: %q", s.Line())
+		}
+		ids = append(ids, e.ID)
+		lines = append(lines, s.LineNumber())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 4 {
+		t.Errorf("unexpected decoded entries: %v", ids)
+	}
+	if len(lines) != 3 || lines[0] != 2 || lines[1] != 4 || lines[2] != 6 {
+		t.Errorf("unexpected line numbers: %v", lines)
+	}
+}
+
+func TestScannerErr(t *testing.T) {
+	var e struct {
+		ID int `hunk:"id"`
+	}
+	p, err := NewParser(":id", &e)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fail := errors.New("read failed")
+	s := p.NewScanner(io.MultiReader(strings.NewReader("1\n"), iotest.ErrReader(fail)))
+	if !s.Scan() {
+		t.Fatal("expected first line to be scanned")
+	}
+	if err := s.Decode(&e); err != nil || e.ID != 1 {
+		t.Errorf("unexpected result: %d, %v", e.ID, err)
+	}
+	if s.Scan() {
+		t.Error("expected scan to stop on read error")
+	}
+	if s.Err() != fail {
+		t.Errorf("expected %s, got %v", fail, s.Err())
+	}
+}
@@ -0,0 +1,29 @@
+package hunkee
+
+// TypedParser is a Parser bound to the structure type T, so passing
+// destination of another type is a compile error.
+type TypedParser[T any] struct {
+	*Parser
+}
+
+// NewTypedParser creates parser of format string for structure T.
+// Options are applied in order.
+func NewTypedParser[T any](format string, opts ...ParserOption) (*TypedParser[T], error) {
+	p, err := NewParser(format, new(T), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedParser[T]{Parser: p}, nil
+}
+
+// Parse parses line into new value of T.
+func (p *TypedParser[T]) Parse(line string) (T, error) {
+	var dst T
+	err := p.parseLine(line, &dst)
+	return dst, err
+}
+
+// ParseInto parses line into dst.
+func (p *TypedParser[T]) ParseInto(line string, dst *T) error {
+	return p.parseLine(line, dst)
+}
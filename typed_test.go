@@ -0,0 +1,39 @@
+package hunkee
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTypedParser(t *testing.T) {
+	type entry struct {
+		ID   int       `hunk:"id"`
+		Name string    `hunk:"name"`
+		Date time.Time `hunk:"date"`
+	}
+
+	p, err := NewTypedParser[entry](":id :name :date")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetTimeLayout("date", "2006-01-02")
+
+	e, err := p.Parse("17 Gordon 2018-07-28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 17 || e.Name != "Gordon" || e.Date.Day() != 28 {
+		t.Errorf("unexpected result: %+v", e)
+	}
+
+	if err = p.ParseInto("18 Alyx 2018-07-29", &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 18 || e.Name != "Alyx" || e.Date.Day() != 29 {
+		t.Errorf("unexpected result: %+v", e)
+	}
+
+	if _, err = NewTypedParser[int](":id"); err != ErrOnlyStructs {
+		t.Errorf("expected %s, got %v", ErrOnlyStructs, err)
+	}
+}
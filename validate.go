@@ -0,0 +1,143 @@
+package hunkee
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidMapping is returned by NewParser with validation enabled
+// if format and structure do not match each other.
+var ErrInvalidMapping = errors.New("format does not match structure")
+
+// IssueKind is a kind of problem found by Validate
+type IssueKind int
+
+const (
+	IssueUnusedField  IssueKind = iota + 1 // field is not mapped on any token of format
+	IssueRawNotString                      // _raw field is not a string
+	IssueNoTimeLayout                      // time layout is not set, RFC3339 is used
+	IssueDuplicateTag                      // several fields have the same tag
+)
+
+// Issue is a problem of format or structure found by Validate
+type Issue struct {
+	Kind  IssueKind
+	Field string // name of structure field, e.g. Upstream.Addr
+	Tag   string
+	Msg   string
+}
+
+func (i Issue) Error() string {
+	return fmt.Sprintf("field %s (%s): %s", i.Field, i.Tag, i.Msg)
+}
+
+// ParserOption configures parser created by NewParser or other constructors.
+// Options are applied in order to created parser. Before that they are applied
+// to parser without mapped fields, so options like WithType could affect mapping.
+type ParserOption func(p *Parser) error
+
+// WithValidation makes NewParser fail with ErrInvalidMapping if Validate
+// reports any issue. Issues are joined to the error. Time layouts should be
+// set up in tags or by options passed before it, e.g. WithTimeLayouts.
+func WithValidation() ParserOption {
+	return func(p *Parser) error {
+		// fields are not mapped yet
+		if p.mapper == nil {
+			return nil
+		}
+		issues := p.Validate()
+		if len(issues) == 0 {
+			return nil
+		}
+		errs := make([]error, len(issues))
+		for i := range issues {
+			errs[i] = issues[i]
+		}
+		return fmt.Errorf("%w: %w", ErrInvalidMapping, errors.Join(errs...))
+	}
+}
+
+// Validate reports problems of format and structure parser was created for,
+// which are not errors, but could lead to unexpected results: struct fields
+// absent in format, _raw fields of non-string types, time fields without
+// explicitly set layout and fields with the same tag. Issues are ordered
+// as fields are declared. Parser created for nil structure has no issues.
+func (p *Parser) Validate() []Issue {
+	m := p.mapper
+	if m.typ == nil {
+		return nil
+	}
+
+	used := make(map[string]bool, len(m.tokensSeq))
+	for _, tag := range m.tokensSeq {
+		used[tag] = true
+	}
+
+	tags := make([]string, 0, len(m.fields))
+	for tag, f := range m.fields {
+		// skip ignored tokens and placeholders of raw fields
+		if f.index != nil {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return lessIndex(m.fields[tags[i]].index, m.fields[tags[j]].index)
+	})
+
+	var issues []Issue
+	for _, tag := range tags {
+		f := m.fields[tag]
+		issue := Issue{Field: fieldName(m.typ, f.index), Tag: tag}
+
+		normal := strings.TrimSuffix(tag, "_raw")
+		if nf, ok := m.fields[normal]; ok && nf.hasRaw && normal != tag {
+			if f.reflectType.Kind() != reflect.String {
+				issue.Kind, issue.Msg = IssueRawNotString, fmt.Sprintf("_raw field has type %s instead of string", f.reflectType)
+				issues = append(issues, issue)
+			}
+			if !used[normal] {
+				issue.Kind, issue.Msg = IssueUnusedField, fmt.Sprintf("neither %q nor %q is present in format", tag, normal)
+				issues = append(issues, issue)
+			}
+		} else if !used[tag] {
+			issue.Kind, issue.Msg = IssueUnusedField, "tag is not present in format"
+			issues = append(issues, issue)
+		} else if f.timeOptions != nil && !f.layoutSet {
+			issue.Kind, issue.Msg = IssueNoTimeLayout, fmt.Sprintf("time layout is not set, %q is used", f.timeOptions.Layout)
+			issues = append(issues, issue)
+		}
+
+		for _, index := range f.shadowed {
+			issues = append(issues, Issue{
+				Kind:  IssueDuplicateTag,
+				Field: fieldName(m.typ, index),
+				Tag:   tag,
+				Msg:   fmt.Sprintf("tag is also used by field %s, which takes precedence", issue.Field),
+			})
+		}
+	}
+	return issues
+}
+
+// fieldName returns name of nested field of structure type t by index
+func fieldName(t reflect.Type, index []int) string {
+	names := make([]string, len(index))
+	for i, x := range index {
+		f := deref(t).Field(x)
+		names[i], t = f.Name, f.Type
+	}
+	return strings.Join(names, ".")
+}
+
+// lessIndex reports whether field with index a is declared before field with index b
+func lessIndex(a, b []int) bool {
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,109 @@
+package hunkee
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	var s struct {
+		ID       int       `hunk:"id"`
+		IDRaw    int       `hunk:"id_raw"`
+		Date     time.Time `hunk:"date"`
+		Added    time.Time `hunk:"added"`
+		Name     string    `hunk:"name"`
+		Nick     string    `hunk:"name"`
+		Comment  string    `hunk:"comment"`
+		Upstream upstream  `hunk:"upstream"`
+	}
+
+	p, err := NewParser(":id :date :added :name :upstream.addr", &s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.SetTimeLayout("added", "2006-01-02")
+
+	expected := []Issue{
+		{Kind: IssueRawNotString, Field: "IDRaw", Tag: "id_raw"},
+		{Kind: IssueNoTimeLayout, Field: "Date", Tag: "date"},
+		{Kind: IssueDuplicateTag, Field: "Name", Tag: "name"},
+		{Kind: IssueUnusedField, Field: "Comment", Tag: "comment"},
+		{Kind: IssueUnusedField, Field: "Upstream.Time", Tag: "upstream.time"},
+		{Kind: IssueUnusedField, Field: "Upstream.TimeRaw", Tag: "upstream.time_raw"},
+	}
+	issues := p.Validate()
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %d: %v", len(expected), len(issues), issues)
+	}
+	for i, issue := range issues {
+		if issue.Kind != expected[i].Kind || issue.Field != expected[i].Field || issue.Tag != expected[i].Tag {
+			t.Errorf("issue %d: expected %+v, got %+v", i, expected[i], issue)
+		}
+	}
+
+	// raw field of other type is not populated instead of panic
+	if err = p.ParseLine("17 2018-07-28T00:00:00Z 2018-07-28 Gordon 10.0.0.1:80", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 17 || s.IDRaw != 0 || s.Nick != "Gordon" {
+		t.Errorf("unexpected result: %+v", s)
+	}
+
+	_, err = NewParser(":id :date :added :name :upstream.addr", &s, WithValidation())
+	if !errors.Is(err, ErrInvalidMapping) || !errors.Is(err, issues[0]) {
+		t.Errorf("expected %s with issues, got %v", ErrInvalidMapping, err)
+	}
+
+	// pointers and sql.Null* are not strings either
+	var wrapped struct {
+		A    int            `hunk:"a"`
+		ARaw *string        `hunk:"a_raw"`
+		B    int            `hunk:"b"`
+		BRaw sql.NullString `hunk:"b_raw"`
+	}
+	if p, err = NewParser(":a :b", &wrapped); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	issues = p.Validate()
+	if len(issues) != 2 || issues[0].Kind != IssueRawNotString || issues[1].Kind != IssueRawNotString {
+		t.Errorf("expected issues of a_raw and b_raw, got %v", issues)
+	}
+	if err = p.ParseLine("1 2", &wrapped); err != nil {
+		t.Fatal(err)
+	}
+	if wrapped.A != 1 || wrapped.ARaw != nil || wrapped.B != 2 || wrapped.BRaw.Valid {
+		t.Errorf("unexpected result: %+v", wrapped)
+	}
+	if l, err := p.FormatLine(&wrapped); err != nil || l != "1 2" {
+		t.Errorf("unexpected line %q, %v", l, err)
+	}
+	if _, err = NewParser(":a :b", &wrapped, WithValidation()); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("expected %s, got %v", ErrInvalidMapping, err)
+	}
+
+	var valid struct {
+		ID    int       `hunk:"id"`
+		IDRaw string    `hunk:"id_raw"`
+		Date  time.Time `hunk:"date,layout=2006-01-02"`
+	}
+	if _, err = NewParser(":id :date", &valid, WithValidation()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// time layouts are checked too, so they are set up before validation
+	var added struct {
+		ID    int       `hunk:"id"`
+		Added time.Time `hunk:"added"`
+	}
+	_, err = NewParser(":id :added", &added, WithValidation())
+	if !errors.Is(err, ErrInvalidMapping) || !strings.Contains(err.Error(), "Added") {
+		t.Errorf("expected %s of added layout, got %v", ErrInvalidMapping, err)
+	}
+	layouts := map[string]string{"added": "2006-01-02"}
+	if _, err = NewParser(":id :added", &added, WithTimeLayouts(layouts), WithValidation()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -54,6 +54,11 @@ func (w *worker) seek(i uint32) *field {
 	return f
 }
 
+// param returns compiled format parameter of the last seeked field
+func (w *worker) param() *namedParameter {
+	return w.parent.params[atomic.LoadUint32(&w.pos)-1]
+}
+
 func (w *worker) next() *field {
 	return w.seek(atomic.LoadUint32(&w.pos))
 }